@@ -0,0 +1,240 @@
+package scim
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// ID identifies the client, e.g., the username of HTTP Basic authentication or the subject of a bearer token.
+	ID string
+	// Type is the authentication type by which the client was authenticated.
+	Type AuthenticationType
+	// Scopes are the scopes that are granted to the client, e.g., the "scope" claim of a bearer token.
+	Scopes []string
+	// Claims contains additional information about the client, e.g., the claims of a bearer token.
+	Claims map[string]interface{}
+}
+
+// principalKey is the context key of the authenticated principal.
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal that was authenticated by one of the authenticators of the server. The
+// second return value is false if the request was not authenticated, e.g., if the server has no authenticators.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// WithPrincipal returns a copy of the given context that contains the given principal. This can be used to pass the
+// principal to handlers outside of an HTTP request, e.g., in batch jobs.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Authenticator authenticates the clients of requests that use a specific HTTP authentication scheme. The
+// authentication schemes that are supported should also be advertised in ServiceProviderConfig.AuthenticationSchemes.
+type Authenticator interface {
+	// Scheme returns the HTTP authentication scheme of the "Authorization" header, e.g., "Bearer" or "Basic".
+	Scheme() string
+	// Challenge returns the challenge of the "WWW-Authenticate" header for the authentication scheme, e.g.,
+	// `Bearer realm="example"`.
+	Challenge() string
+	// Authenticate verifies the credentials of the "Authorization" header, i.e. the part after the authentication
+	// scheme, and returns the authenticated principal. Errors are returned to the client as a 401 Unauthorized
+	// response, unless a SCIM error is returned.
+	Authenticate(ctx context.Context, credentials string) (Principal, error)
+}
+
+// TokenVerifier verifies the given bearer token and returns the principal to which it was issued.
+type TokenVerifier func(ctx context.Context, token string) (Principal, error)
+
+// StaticTokens returns a token verifier that only accepts the given tokens, each of them mapped on the principal that
+// they authenticate.
+func StaticTokens(tokens map[string]Principal) TokenVerifier {
+	return func(_ context.Context, token string) (Principal, error) {
+		for t, principal := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return principal, nil
+			}
+		}
+		return Principal{}, fmt.Errorf("invalid bearer token")
+	}
+}
+
+// BearerTokenAuthenticator authenticates clients with OAuth 2.0 bearer tokens, i.e. the "oauthbearertoken"
+// authentication type.
+// More info: https://datatracker.ietf.org/doc/html/rfc6750
+type BearerTokenAuthenticator struct {
+	// Realm is the protection space that is included in the challenge.
+	Realm string
+	// Verify verifies the bearer tokens.
+	Verify TokenVerifier
+}
+
+// Scheme returns the "Bearer" authentication scheme.
+func (a BearerTokenAuthenticator) Scheme() string {
+	return "Bearer"
+}
+
+// Challenge returns the challenge of the "WWW-Authenticate" header.
+func (a BearerTokenAuthenticator) Challenge() string {
+	return challenge(a.Scheme(), a.Realm)
+}
+
+// Authenticate verifies the given bearer token.
+func (a BearerTokenAuthenticator) Authenticate(ctx context.Context, token string) (Principal, error) {
+	if token == "" {
+		return Principal{}, fmt.Errorf("missing bearer token")
+	}
+	principal, err := a.Verify(ctx, token)
+	if err != nil {
+		return Principal{}, err
+	}
+	if principal.Type == "" {
+		principal.Type = AuthenticationTypeOauthBearerToken
+	}
+	return principal, nil
+}
+
+// invalidTokenChallenge returns the challenge of the "WWW-Authenticate" header for requests of which the bearer token
+// was rejected, which includes the "invalid_token" error code.
+// More info: https://datatracker.ietf.org/doc/html/rfc6750#section-3.1
+func (a BearerTokenAuthenticator) invalidTokenChallenge() string {
+	if a.Realm == "" {
+		return a.Scheme() + ` error="invalid_token"`
+	}
+	return a.Challenge() + `, error="invalid_token"`
+}
+
+// CredentialsVerifier verifies the given username and password and returns the principal to which they belong.
+type CredentialsVerifier func(ctx context.Context, username, password string) (Principal, error)
+
+// StaticCredentials returns a credentials verifier that only accepts the given passwords, mapped by their username.
+// The identifier of the authenticated principal is the username.
+func StaticCredentials(passwords map[string]string) CredentialsVerifier {
+	return func(_ context.Context, username, password string) (Principal, error) {
+		expected, ok := passwords[username]
+		if !ok || subtle.ConstantTimeCompare([]byte(expected), []byte(password)) != 1 {
+			return Principal{}, fmt.Errorf("invalid username or password")
+		}
+		return Principal{ID: username}, nil
+	}
+}
+
+// BasicAuthenticator authenticates clients with a username and password, i.e. the "httpbasic" authentication type.
+// More info: https://datatracker.ietf.org/doc/html/rfc7617
+type BasicAuthenticator struct {
+	// Realm is the protection space that is included in the challenge.
+	Realm string
+	// Verify verifies the usernames and passwords.
+	Verify CredentialsVerifier
+}
+
+// Scheme returns the "Basic" authentication scheme.
+func (a BasicAuthenticator) Scheme() string {
+	return "Basic"
+}
+
+// Challenge returns the challenge of the "WWW-Authenticate" header.
+func (a BasicAuthenticator) Challenge() string {
+	return challenge(a.Scheme(), a.Realm) + `, charset="UTF-8"`
+}
+
+// Authenticate verifies the given base64 encoded username and password.
+func (a BasicAuthenticator) Authenticate(ctx context.Context, credentials string) (Principal, error) {
+	raw, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return Principal{}, fmt.Errorf("invalid basic credentials: %v", err)
+	}
+	userPass := strings.SplitN(string(raw), ":", 2)
+	if len(userPass) != 2 {
+		return Principal{}, fmt.Errorf("invalid basic credentials")
+	}
+	principal, err := a.Verify(ctx, userPass[0], userPass[1])
+	if err != nil {
+		return Principal{}, err
+	}
+	if principal.Type == "" {
+		principal.Type = AuthenticationTypeHTTPBasic
+	}
+	return principal, nil
+}
+
+// challenge returns the challenge of the given authentication scheme with the given realm.
+func challenge(scheme, realm string) string {
+	if realm == "" {
+		return scheme
+	}
+	return fmt.Sprintf("%s realm=%q", scheme, realm)
+}
+
+// authenticate authenticates the client of the given request with the authenticator that corresponds with the
+// authentication scheme of the "Authorization" header. It returns the request that contains the authenticated
+// principal, see PrincipalFromContext.
+func (s Server) authenticate(r *http.Request) (*http.Request, *errors.ScimError) {
+	if len(s.Authenticators) == 0 {
+		return r, nil
+	}
+
+	scheme, credentials := parseAuthorization(r)
+	for _, authenticator := range s.Authenticators {
+		if !strings.EqualFold(scheme, authenticator.Scheme()) {
+			continue
+		}
+		principal, err := authenticator.Authenticate(r.Context(), credentials)
+		if err != nil {
+			if scimErr, ok := err.(errors.ScimError); ok {
+				return r, &scimErr
+			}
+			scimErr := errors.ScimErrorUnauthorized("The provided credentials are invalid.")
+			return r, &scimErr
+		}
+		return r.WithContext(WithPrincipal(r.Context(), principal)), nil
+	}
+
+	scimErr := errors.ScimErrorUnauthorized("Authentication is required to access this endpoint.")
+	return r, &scimErr
+}
+
+// challenges sets the "WWW-Authenticate" header to the challenges of all the authenticators of the server. The
+// challenge of the bearer token authenticator includes an error code if the given request contains a bearer token,
+// since it was rejected.
+func (s Server) challenges(w http.ResponseWriter, r *http.Request) {
+	scheme, credentials := parseAuthorization(r)
+	for _, authenticator := range s.Authenticators {
+		challenge := authenticator.Challenge()
+		if bearer, ok := authenticator.(BearerTokenAuthenticator); ok && credentials != "" && strings.EqualFold(scheme, bearer.Scheme()) {
+			challenge = bearer.invalidTokenChallenge()
+		}
+		w.Header().Add("WWW-Authenticate", challenge)
+	}
+}
+
+// isDiscoveryEndpoint reports whether the given path refers to one of the discovery endpoints, i.e.
+// "/ServiceProviderConfig", "/Schemas" or "/ResourceTypes".
+func isDiscoveryEndpoint(path string) bool {
+	for _, endpoint := range []string{"/ServiceProviderConfig", "/Schemas", "/ResourceTypes"} {
+		if path == endpoint || strings.HasPrefix(path, endpoint+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAuthorization returns the authentication scheme and the credentials of the "Authorization" header of the given
+// request.
+func parseAuthorization(r *http.Request) (scheme, credentials string) {
+	authorization := strings.SplitN(strings.TrimSpace(r.Header.Get("Authorization")), " ", 2)
+	if len(authorization) == 2 {
+		credentials = strings.TrimSpace(authorization[1])
+	}
+	return authorization[0], credentials
+}
@@ -0,0 +1,144 @@
+package scim
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerAuthentication(t *testing.T) {
+	basic := func(username, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+
+	tests := []struct {
+		name               string
+		target             string
+		authorization      string
+		expectedStatusCode int
+		invalidToken       bool
+	}{
+		{name: "missing", target: "/Users", expectedStatusCode: http.StatusUnauthorized},
+		{name: "unsupported scheme", target: "/Users", authorization: "Digest username=\"admin\"", expectedStatusCode: http.StatusUnauthorized},
+		{name: "bearer", target: "/Users", authorization: "Bearer s3cr3t", expectedStatusCode: http.StatusOK},
+		{name: "bearer case insensitive", target: "/Users", authorization: "bearer s3cr3t", expectedStatusCode: http.StatusOK},
+		{name: "invalid bearer", target: "/Users", authorization: "Bearer secret", expectedStatusCode: http.StatusUnauthorized, invalidToken: true},
+		{name: "empty bearer", target: "/Users", authorization: "Bearer", expectedStatusCode: http.StatusUnauthorized},
+		{name: "basic", target: "/Users", authorization: basic("admin", "passw0rd"), expectedStatusCode: http.StatusOK},
+		{name: "invalid basic", target: "/Users", authorization: basic("admin", "password"), expectedStatusCode: http.StatusUnauthorized},
+		{name: "malformed basic", target: "/Users", authorization: "Basic admin", expectedStatusCode: http.StatusUnauthorized},
+		{name: "unknown endpoint", target: "/Unknown", expectedStatusCode: http.StatusUnauthorized},
+		{name: "public discovery", target: "/v2/Schemas", expectedStatusCode: http.StatusOK},
+		{name: "public discovery resource", target: "/ResourceTypes/User", expectedStatusCode: http.StatusOK},
+	}
+
+	s := newTestServer()
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{
+			Realm: "scim",
+			Verify: StaticTokens(map[string]Principal{
+				"s3cr3t": {ID: "client"},
+			}),
+		},
+		BasicAuthenticator{
+			Realm:  "scim",
+			Verify: StaticCredentials(map[string]string{"admin": "passw0rd"}),
+		},
+	}
+	s.PublicDiscovery = true
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+			if rr.Code != http.StatusUnauthorized {
+				assertLen(t, rr.Header().Values("WWW-Authenticate"), 0)
+				return
+			}
+			bearerChallenge := `Bearer realm="scim"`
+			if test.invalidToken {
+				// A bearer token was presented, but rejected.
+				bearerChallenge += `, error="invalid_token"`
+			}
+			assertEqualStrings(t, []string{
+				bearerChallenge,
+				`Basic realm="scim", charset="UTF-8"`,
+			}, rr.Header().Values("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestServerAuthenticationInvalidToken(t *testing.T) {
+	s := newTestServer()
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{Verify: StaticTokens(map[string]Principal{
+			"s3cr3t": {ID: "client"},
+		})},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/Users", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusUnauthorized, rr.Code)
+	assertEqualStrings(t, []string{`Bearer error="invalid_token"`}, rr.Header().Values("WWW-Authenticate"))
+
+	// Requests without a bearer token do not get an error code.
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/Users", nil))
+	assertEqualStatusCode(t, http.StatusUnauthorized, rr.Code)
+	assertEqualStrings(t, []string{"Bearer"}, rr.Header().Values("WWW-Authenticate"))
+}
+
+func TestServerAuthenticationDiscovery(t *testing.T) {
+	s := newTestServer()
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{Verify: StaticTokens(map[string]Principal{
+			"s3cr3t": {ID: "client"},
+		})},
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil))
+	assertEqualStatusCode(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestServerAuthenticationPrincipal(t *testing.T) {
+	var principal Principal
+	s := newTestServer()
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{Verify: StaticTokens(map[string]Principal{
+			"s3cr3t": {ID: "client"},
+		})},
+	}
+	s.MeResolver = func(r *http.Request) (string, string, error) {
+		principal, _ = PrincipalFromContext(r.Context())
+		return "User", "0001", nil
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/Me", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	assertEqual(t, "client", principal.ID)
+	assertEqual(t, AuthenticationTypeOauthBearerToken, principal.Type)
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assertEqual(t, false, ok)
+
+	principal, ok := PrincipalFromContext(WithPrincipal(context.Background(), Principal{ID: "batch"}))
+	assertEqual(t, true, ok)
+	assertEqual(t, "batch", principal.ID)
+}
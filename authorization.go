@@ -0,0 +1,466 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// AuthorizationPolicy is a declarative policy that limits the operations that clients can perform on the resources of
+// each resource type, and the attributes that they can access while doing so. Clients are identified by the
+// identifier of the principal that was authenticated by the authenticators of the server, see Principal.
+//
+// Requests that are not permitted get a 403 Forbidden response. Filters and "sortBy" parameters can only refer to
+// attributes that the client can read, and all other attributes are removed from the returned resources.
+type AuthorizationPolicy struct {
+	// Clients maps the identifiers of the principals on their permissions.
+	Clients map[string]Permissions
+	// Default are the permissions of the clients that are not in Clients, including unauthenticated clients. These
+	// clients have no permissions at all if nil.
+	Default Permissions
+}
+
+// Permissions maps the names of the resource types on the operations that a client can perform on their resources.
+// Clients can not perform any operation on resource types that are not in the map.
+type Permissions map[string]OperationPermissions
+
+// OperationPermissions maps the operations that a client can perform on the attribute paths that it can access while
+// doing so, e.g., "userName", "name.givenName" or "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User" for all
+// the attributes of the schema extension. The path "*" grants access to all attributes. Operations that are not in
+// the map are not permitted. The attribute paths of delete operations are ignored.
+//
+// The attributes of create operations and the targets of patch operations must all be accessible. Since replace
+// operations replace all attributes, including the ones that are left out, all attributes that are not read-only must
+// be accessible to replace resources.
+type OperationPermissions map[Operation][]string
+
+// permissions returns the permissions of the client of the given request.
+func (p AuthorizationPolicy) permissions(r *http.Request) Permissions {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		if permissions, ok := p.Clients[principal.ID]; ok {
+			return permissions
+		}
+	}
+	return p.Default
+}
+
+// authorize returns the attributes that the client of the given request can access when performing the given
+// operation on the resources of the given resource type. A 403 Forbidden SCIM error is returned if the operation is not
+// permitted at all.
+func (s Server) authorize(r *http.Request, resourceType ResourceType, op Operation) (attributeAccess, *errors.ScimError) {
+	if s.Authorization == nil {
+		return attributeAccess{}, nil
+	}
+
+	paths, ok := s.Authorization.permissions(r)[resourceType.Name][op]
+	if !ok {
+		scimErr := errors.ScimErrorForbidden(fmt.Sprintf(
+			"The client is not permitted to %s resources of type %s.", op, resourceType.Name,
+		))
+		return attributeAccess{}, &scimErr
+	}
+	return newAttributeAccess(resourceType, paths), nil
+}
+
+// readAccess returns the attributes that the client of the given request can read of the resources of the given
+// resource type. No attributes are readable if the client is not permitted to read the resources at all.
+func (s Server) readAccess(r *http.Request, resourceType ResourceType) attributeAccess {
+	access, scimErr := s.authorize(r, resourceType, OperationRead)
+	if scimErr != nil {
+		return attributeAccess{restricted: true}
+	}
+	return access
+}
+
+// authorizeOperation checks whether the client of the given request is permitted to perform the given operation,
+// including whether it can access all the attributes that are targeted by the operation.
+func (s Server) authorizeOperation(r *http.Request, input OperationInput) *errors.ScimError {
+	access, scimErr := s.authorize(r, input.ResourceType, input.Operation)
+	if scimErr != nil {
+		return scimErr
+	}
+
+	var path string
+	switch input.Operation {
+	case OperationCreate:
+		path = access.checkAttributes(input.ResourceType, input.Attributes)
+	case OperationReplace:
+		path = access.checkReplace(input.ResourceType)
+	case OperationPatch:
+		path = access.checkPatch(input.ResourceType, input.PatchOperations)
+	}
+	if path != "" {
+		scimErr := errors.ScimErrorForbidden(fmt.Sprintf(
+			"The client is not permitted to %s the attribute %s of resources of type %s.",
+			input.Operation, path, input.ResourceType.Name,
+		))
+		return &scimErr
+	}
+	return nil
+}
+
+// authorizeList checks whether the client of the given request is permitted to read the resources of the given
+// resource type, and whether the filter and "sortBy" parameters only refer to attributes that it can read.
+func (s Server) authorizeList(r *http.Request, resourceType ResourceType, params ListRequestParams) *errors.ScimError {
+	access, scimErr := s.authorize(r, resourceType, OperationRead)
+	if scimErr != nil {
+		return scimErr
+	}
+
+	path := access.checkFilter(resourceType, params.Filter)
+	if path == "" && params.SortBy != nil && !access.allowsPath(resourceType, *params.SortBy, "") {
+		path = params.SortBy.String()
+	}
+	if path != "" {
+		scimErr := errors.ScimErrorForbidden(fmt.Sprintf(
+			"The client is not permitted to read the attribute %s of resources of type %s.", path, resourceType.Name,
+		))
+		return &scimErr
+	}
+	return nil
+}
+
+// attributeGrant grants access to an attribute, to a sub-attribute if subAttribute is not empty or to all attributes
+// of a schema if name is empty.
+type attributeGrant struct {
+	schemaID     string
+	name         string
+	subAttribute string
+}
+
+// attributeAccess represents the attributes that can be accessed. The zero value grants access to all attributes.
+type attributeAccess struct {
+	restricted bool
+	grants     []attributeGrant
+}
+
+// newAttributeAccess returns the access to the attributes of the resources of the given resource type that is granted
+// by the given attribute paths.
+func newAttributeAccess(resourceType ResourceType, paths []string) attributeAccess {
+	access := attributeAccess{restricted: true}
+	for _, raw := range paths {
+		raw = strings.TrimSpace(raw)
+		if raw == "*" {
+			return attributeAccess{}
+		}
+		if extension, ok := resourceType.getSchemaExtension(raw); ok {
+			access.grants = append(access.grants, attributeGrant{schemaID: extension.ID})
+			continue
+		}
+		path, err := filter.ParseAttrPath([]byte(raw))
+		if err != nil {
+			continue
+		}
+		schemaID, name := resolveAttribute(resourceType, path)
+		access.grants = append(access.grants, attributeGrant{
+			schemaID:     schemaID,
+			name:         name,
+			subAttribute: path.SubAttributeName(),
+		})
+	}
+	return access
+}
+
+// resolveAttribute returns the id of the schema and the name of the attribute to which the given path refers.
+func resolveAttribute(resourceType ResourceType, path filter.AttributePath) (string, string) {
+	if s, attr, ok := getAttribute(path, resourceType.Schema, resourceType.getSchemaExtensions()...); ok {
+		return s.ID, attr.Name()
+	}
+	if uri := path.URI(); uri != "" {
+		return uri, path.AttributeName
+	}
+	return resourceType.Schema.ID, path.AttributeName
+}
+
+// allows reports whether the (sub-)attribute with the given name can be accessed. An empty sub-attribute name refers
+// to the attribute as a whole.
+func (a attributeAccess) allows(schemaID, name, subAttribute string) bool {
+	if !a.restricted {
+		return true
+	}
+	for _, g := range a.grants {
+		if !strings.EqualFold(g.schemaID, schemaID) {
+			continue
+		}
+		if g.name == "" {
+			return true
+		}
+		if !strings.EqualFold(g.name, name) {
+			continue
+		}
+		if g.subAttribute == "" || subAttribute != "" && strings.EqualFold(g.subAttribute, subAttribute) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsPath reports whether the attribute to which the given path refers can be accessed. If subAttribute is not
+// empty, it overrides the sub-attribute of the path.
+func (a attributeAccess) allowsPath(resourceType ResourceType, path filter.AttributePath, subAttribute string) bool {
+	schemaID, name := resolveAttribute(resourceType, path)
+	if subAttribute == "" {
+		subAttribute = path.SubAttributeName()
+	}
+	return a.allows(schemaID, name, subAttribute)
+}
+
+// allowsValue reports whether the given value of the attribute with the given name can be accessed. Complex values
+// can be accessed if all their sub-attributes can be accessed.
+func (a attributeAccess) allowsValue(schemaID, name string, value interface{}) bool {
+	if a.allows(schemaID, name, "") {
+		return true
+	}
+	for _, v := range toSlice(value) {
+		complexValue, ok := asMap(v)
+		if !ok {
+			return false
+		}
+		for k := range complexValue {
+			if !a.allows(schemaID, name, k) {
+				return false
+			}
+		}
+	}
+	return value != nil
+}
+
+// checkAttributes returns the path of the first of the given attributes that can not be accessed, or an empty string
+// if all of them can be accessed.
+func (a attributeAccess) checkAttributes(resourceType ResourceType, attributes ResourceAttributes) string {
+	if !a.restricted {
+		return ""
+	}
+	for k, v := range attributes {
+		if extension, ok := resourceType.getSchemaExtension(k); ok {
+			extensionAttributes, _ := asMap(v)
+			for name, v := range extensionAttributes {
+				if !a.allowsValue(extension.ID, name, v) {
+					return extension.ID + ":" + name
+				}
+			}
+			continue
+		}
+		if !a.allowsValue(resourceType.Schema.ID, k, v) {
+			return k
+		}
+	}
+	return ""
+}
+
+// checkReplace returns the path of the first attribute of the resources of the given resource type that is replaced by
+// a replace operation and can not be accessed, or an empty string if all of them can be accessed. Read-only
+// (sub-)attributes are ignored, since they are not replaced.
+func (a attributeAccess) checkReplace(resourceType ResourceType) string {
+	if !a.restricted {
+		return ""
+	}
+	check := func(schemaID, prefix string, attributes schema.Attributes) string {
+		for _, attr := range attributes {
+			if attributeMutability(attr) == "readOnly" || a.allows(schemaID, attr.Name(), "") {
+				continue
+			}
+			subAttributes := attr.SubAttributes()
+			if len(subAttributes) == 0 {
+				return prefix + attr.Name()
+			}
+			for _, subAttr := range subAttributes {
+				if attributeMutability(subAttr) != "readOnly" && !a.allows(schemaID, attr.Name(), subAttr.Name()) {
+					return prefix + attr.Name() + "." + subAttr.Name()
+				}
+			}
+		}
+		return ""
+	}
+
+	if path := check(resourceType.Schema.ID, "", resourceType.Schema.Attributes); path != "" {
+		return path
+	}
+	for _, extension := range resourceType.SchemaExtensions {
+		if path := check(extension.Schema.ID, extension.Schema.ID+":", extension.Schema.Attributes); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// checkPatch returns the path of the first attribute that is targeted by the given operations that can not be
+// accessed, or an empty string if all of them can be accessed.
+func (a attributeAccess) checkPatch(resourceType ResourceType, operations []PatchOperation) string {
+	if !a.restricted {
+		return ""
+	}
+	for _, op := range operations {
+		if op.Path != nil {
+			if !a.allowsPath(resourceType, op.Path.AttributePath, subAttributeName(*op.Path)) {
+				return op.Path.String()
+			}
+			continue
+		}
+
+		value, _ := asMap(op.Value)
+		for k, v := range value {
+			if extension, ok := resourceType.getSchemaExtension(k); ok {
+				if path := a.checkAttributes(resourceType, ResourceAttributes{extension.ID: v}); path != "" {
+					return path
+				}
+				continue
+			}
+			path, err := filter.ParseAttrPath([]byte(k))
+			if err != nil {
+				return k
+			}
+			schemaID, name := resolveAttribute(resourceType, path)
+			if subAttribute := path.SubAttributeName(); subAttribute != "" {
+				if !a.allows(schemaID, name, subAttribute) {
+					return k
+				}
+				continue
+			}
+			if !a.allowsValue(schemaID, name, v) {
+				return k
+			}
+		}
+	}
+	return ""
+}
+
+// checkFilter returns the path of the first attribute in the given filter that can not be accessed, or an empty
+// string if all of them can be accessed.
+func (a attributeAccess) checkFilter(resourceType ResourceType, expression filter.Expression) string {
+	if !a.restricted || expression == nil {
+		return ""
+	}
+	switch e := expression.(type) {
+	case *filter.AttributeExpression:
+		if !a.allowsPath(resourceType, e.AttributePath, "") {
+			return e.AttributePath.String()
+		}
+	case *filter.ValuePath:
+		if !a.allowsPath(resourceType, e.AttributePath, "") {
+			// The attributes in the value filter are sub-attributes of the attribute of the value path.
+			return a.checkValueFilter(resourceType, e.AttributePath, e.ValueFilter)
+		}
+	case *filter.LogicalExpression:
+		if path := a.checkFilter(resourceType, e.Left); path != "" {
+			return path
+		}
+		return a.checkFilter(resourceType, e.Right)
+	case *filter.NotExpression:
+		return a.checkFilter(resourceType, e.Expression)
+	}
+	return ""
+}
+
+// checkValueFilter returns the path of the first sub-attribute of the given attribute in the given value filter that
+// can not be accessed, or an empty string if all of them can be accessed.
+func (a attributeAccess) checkValueFilter(resourceType ResourceType, parent filter.AttributePath, expression filter.Expression) string {
+	switch e := expression.(type) {
+	case *filter.AttributeExpression:
+		if !a.allowsPath(resourceType, parent, e.AttributePath.AttributeName) {
+			return parent.String() + "." + e.AttributePath.AttributeName
+		}
+	case *filter.LogicalExpression:
+		if path := a.checkValueFilter(resourceType, parent, e.Left); path != "" {
+			return path
+		}
+		return a.checkValueFilter(resourceType, parent, e.Right)
+	case *filter.NotExpression:
+		return a.checkValueFilter(resourceType, parent, e.Expression)
+	}
+	return ""
+}
+
+// filterResource returns the attributes of the given resource that can be accessed. The "schemas", "id" and "meta"
+// attributes are always returned.
+func (a attributeAccess) filterResource(resourceType ResourceType, resource ResourceAttributes) ResourceAttributes {
+	if !a.restricted {
+		return resource
+	}
+
+	filtered := make(ResourceAttributes)
+	for k, v := range resource {
+		switch {
+		case strings.EqualFold(k, "schemas"),
+			strings.EqualFold(k, schema.CommonAttributeID),
+			strings.EqualFold(k, schema.CommonAttributeMeta):
+			filtered[k] = v
+			continue
+		}
+
+		if extension, ok := resourceType.getSchemaExtension(k); ok {
+			extensionAttributes, ok := asMap(v)
+			if !ok {
+				continue
+			}
+			filteredExtension := make(map[string]interface{})
+			for name, v := range extensionAttributes {
+				if value, ok := a.filterValue(extension.ID, name, v); ok {
+					filteredExtension[name] = value
+				}
+			}
+			if len(filteredExtension) != 0 {
+				filtered[k] = filteredExtension
+			}
+			continue
+		}
+
+		if value, ok := a.filterValue(resourceType.Schema.ID, k, v); ok {
+			filtered[k] = value
+		}
+	}
+	return filtered
+}
+
+// filterValue returns the given value of the attribute with the given name without the sub-attributes that can not be
+// accessed, and whether any part of the value can be accessed.
+func (a attributeAccess) filterValue(schemaID, name string, value interface{}) (interface{}, bool) {
+	if a.allows(schemaID, name, "") {
+		return value, true
+	}
+
+	var subAttributes []string
+	for _, g := range a.grants {
+		if strings.EqualFold(g.schemaID, schemaID) && strings.EqualFold(g.name, name) && g.subAttribute != "" {
+			subAttributes = append(subAttributes, g.subAttribute)
+		}
+	}
+	if len(subAttributes) == 0 {
+		return nil, false
+	}
+
+	filterComplexValue := func(value interface{}) interface{} {
+		complexValue, ok := asMap(value)
+		if !ok {
+			return nil
+		}
+		filtered := make(map[string]interface{})
+		for k, v := range complexValue {
+			if containsFold(subAttributes, k) {
+				filtered[k] = v
+			}
+		}
+		return filtered
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, v := range v {
+			values[i] = filterComplexValue(v)
+		}
+		return values, true
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, v := range v {
+			values[i] = filterComplexValue(v)
+		}
+		return values, true
+	default:
+		filtered := filterComplexValue(v)
+		return filtered, filtered != nil
+	}
+}
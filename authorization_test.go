@@ -0,0 +1,241 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerAuthorization(t *testing.T) {
+	tests := []struct {
+		name               string
+		client             string
+		method             string
+		target             string
+		body               string
+		expectedStatusCode int
+	}{
+		{name: "read all", client: "admin", method: http.MethodGet, target: "/Users/0001", expectedStatusCode: http.StatusOK},
+		{name: "read", client: "reader", method: http.MethodGet, target: "/Users/0001", expectedStatusCode: http.StatusOK},
+		{name: "read not permitted", client: "reader", method: http.MethodGet, target: "/Groups", expectedStatusCode: http.StatusForbidden},
+		{name: "read default", client: "guest", method: http.MethodGet, target: "/Groups", expectedStatusCode: http.StatusOK},
+		{name: "read default not permitted", client: "guest", method: http.MethodGet, target: "/Users", expectedStatusCode: http.StatusForbidden},
+		{name: "filter", client: "reader", method: http.MethodGet, target: `/Users?filter=userName+eq+"test01"`, expectedStatusCode: http.StatusOK},
+		{name: "filter sub-attribute", client: "reader", method: http.MethodGet, target: `/Users?filter=name.givenName+pr`, expectedStatusCode: http.StatusOK},
+		{name: "filter not permitted", client: "reader", method: http.MethodGet, target: `/Users?filter=active+eq+true`, expectedStatusCode: http.StatusForbidden},
+		{name: "filter sub-attribute not permitted", client: "reader", method: http.MethodGet, target: `/Users?filter=name[familyName+pr]`, expectedStatusCode: http.StatusForbidden},
+		{name: "filter logical not permitted", client: "reader", method: http.MethodGet, target: `/Users?filter=userName+pr+and+not+(active+eq+true)`, expectedStatusCode: http.StatusForbidden},
+		{name: "filter extension", client: "reader", method: http.MethodGet, target: `/EnterpriseUsers?filter=urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber+pr`, expectedStatusCode: http.StatusOK},
+		{name: "sort not permitted", client: "reader", method: http.MethodGet, target: `/Users?sortBy=displayName`, expectedStatusCode: http.StatusForbidden},
+		{name: "search not permitted", client: "reader", method: http.MethodPost, target: "/Users/.search", body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "filter": "active pr"}`, expectedStatusCode: http.StatusForbidden},
+		{name: "create", client: "writer", method: http.MethodPost, target: "/Users", body: `{"userName": "new", "name": {"givenName": "New"}}`, expectedStatusCode: http.StatusCreated},
+		{name: "create not permitted", client: "reader", method: http.MethodPost, target: "/Users", body: `{"userName": "new"}`, expectedStatusCode: http.StatusForbidden},
+		{name: "create attribute not permitted", client: "writer", method: http.MethodPost, target: "/Users", body: `{"userName": "new", "active": true}`, expectedStatusCode: http.StatusForbidden},
+		{name: "create sub-attribute not permitted", client: "writer", method: http.MethodPost, target: "/Users", body: `{"userName": "new", "name": {"familyName": "New"}}`, expectedStatusCode: http.StatusForbidden},
+		{name: "replace", client: "editor", method: http.MethodPut, target: "/Users/0001", body: `{"userName": "test01", "name": {"familyName": "Test"}}`, expectedStatusCode: http.StatusOK},
+		{name: "replace attribute not permitted", client: "writer", method: http.MethodPut, target: "/Users/0001", body: `{"userName": "test01", "displayName": "Test"}`, expectedStatusCode: http.StatusForbidden},
+		{name: "replace without attribute not permitted", client: "writer", method: http.MethodPut, target: "/Users/0001", body: `{"userName": "test01", "name": {"familyName": "Test"}}`, expectedStatusCode: http.StatusForbidden},
+		{name: "patch", client: "writer", method: http.MethodPatch, target: "/Users/0001", body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "active", "value": false}]}`, expectedStatusCode: http.StatusOK},
+		{name: "patch without path", client: "writer", method: http.MethodPatch, target: "/Users/0001", body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "value": {"name.givenName": "Test"}}]}`, expectedStatusCode: http.StatusOK},
+		{name: "patch attribute not permitted", client: "writer", method: http.MethodPatch, target: "/Users/0001", body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "name.familyName", "value": "Test"}]}`, expectedStatusCode: http.StatusForbidden},
+		{name: "patch without path not permitted", client: "writer", method: http.MethodPatch, target: "/Users/0001", body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "value": {"userName": "test"}}]}`, expectedStatusCode: http.StatusForbidden},
+		{name: "delete", client: "admin", method: http.MethodDelete, target: "/Users/0001", expectedStatusCode: http.StatusNoContent},
+		{name: "delete not permitted", client: "writer", method: http.MethodDelete, target: "/Users/0001", expectedStatusCode: http.StatusForbidden},
+	}
+
+	authenticators := []Authenticator{
+		BearerTokenAuthenticator{
+			Verify: StaticTokens(map[string]Principal{
+				"admin":  {ID: "admin"},
+				"reader": {ID: "reader"},
+				"writer": {ID: "writer"},
+				"editor": {ID: "editor"},
+				"guest":  {ID: "guest"},
+			}),
+		},
+	}
+	policy := &AuthorizationPolicy{
+		Clients: map[string]Permissions{
+			"admin": {
+				"User": {
+					OperationRead:    {"*"},
+					OperationCreate:  {"*"},
+					OperationReplace: {"*"},
+					OperationPatch:   {"*"},
+					OperationDelete:  nil,
+				},
+			},
+			"reader": {
+				"User": {
+					OperationRead: {"userName", "name.givenName"},
+				},
+				"EnterpriseUser": {
+					OperationRead: {"userName", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"},
+				},
+			},
+			"writer": {
+				"User": {
+					OperationRead:    {"userName"},
+					OperationCreate:  {"userName", "name.givenName"},
+					OperationReplace: {"userName", "name"},
+					OperationPatch:   {"name.givenName", "active"},
+				},
+			},
+			"editor": {
+				"User": {
+					OperationReplace: {"userName", "active", "immutableThing", "name.givenName", "name.familyName", "displayName", "emails"},
+				},
+			},
+		},
+		Default: Permissions{
+			"Group": {
+				OperationRead: {"displayName"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer()
+			s.Authenticators = authenticators
+			s.Authorization = policy
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set("Authorization", "Bearer "+test.client)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+		})
+	}
+}
+
+func TestServerAuthorizationReadableAttributes(t *testing.T) {
+	s := newTestServer()
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{
+			Verify: StaticTokens(map[string]Principal{
+				"reader": {ID: "reader"},
+				"writer": {ID: "writer"},
+			}),
+		},
+	}
+	s.Authorization = &AuthorizationPolicy{
+		Clients: map[string]Permissions{
+			"reader": {
+				"User": {
+					OperationRead: {"userName", "name.givenName"},
+				},
+				"EnterpriseUser": {
+					OperationRead: {"userName", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"},
+				},
+			},
+			"writer": {
+				"User": {
+					OperationRead:   {"userName"},
+					OperationCreate: {"userName", "name.givenName"},
+				},
+			},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/Users/0001", nil)
+	req.Header.Set("Authorization", "Bearer reader")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var resource map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+	assertEqual(t, "0001", resource["id"])
+	assertEqual(t, "test01", resource["userName"])
+	assertNotNil(t, resource["meta"], "meta")
+	assertNil(t, resource["externalId"], "externalId")
+
+	// Resource types that can not be read are skipped by the root endpoint.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer reader")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response listResponse
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertEqual(t, 40, response.TotalResults)
+
+	// Write responses are limited to the readable attributes as well.
+	req = httptest.NewRequest(http.MethodPost, "/Users", strings.NewReader(`{"userName": "new", "name": {"givenName": "New"}}`))
+	req.Header.Set("Authorization", "Bearer writer")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusCreated, rr.Code)
+
+	resource = nil
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+	assertEqual(t, "new", resource["userName"])
+	assertNil(t, resource["name"], "name")
+}
+
+// testCountingHandler is a resource handler that counts the resources that are retrieved.
+type testCountingHandler struct {
+	ResourceHandler
+	gets *int
+}
+
+func (h testCountingHandler) Get(r *http.Request, id string) (Resource, error) {
+	*h.gets++
+	return h.ResourceHandler.Get(r, id)
+}
+
+func TestServerAuthorizationBeforeHandler(t *testing.T) {
+	var gets int
+	s := newTestServer()
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{
+			Verify: StaticTokens(map[string]Principal{
+				"reader": {ID: "reader"},
+			}),
+		},
+	}
+	s.Authorization = &AuthorizationPolicy{
+		Clients: map[string]Permissions{
+			"reader": {
+				"User": {
+					OperationRead: {"userName", "name.givenName"},
+				},
+			},
+		},
+	}
+	s.Config.SupportETag = true
+	s.ResourceTypes[0].PatchByReplace = true
+	s.ResourceTypes[0].Handler = testCountingHandler{ResourceHandler: s.ResourceTypes[0].Handler, gets: &gets}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{name: "delete", method: http.MethodDelete, target: "/Users/0001"},
+		{name: "delete unknown", method: http.MethodDelete, target: "/Users/9999"},
+		{name: "replace", method: http.MethodPut, target: "/Users/0001", body: `{"userName": "test01"}`},
+		{name: "replace unknown", method: http.MethodPut, target: "/Users/9999", body: `{"userName": "test01"}`},
+		{name: "patch", method: http.MethodPatch, target: "/Users/0001", body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "active", "value": false}]}`},
+		{name: "patch unknown", method: http.MethodPatch, target: "/Users/9999", body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "active", "value": false}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set("Authorization", "Bearer reader")
+			req.Header.Set("If-Match", `W/"v1"`)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			// The response does not reveal whether the resource exists.
+			assertEqualStatusCode(t, http.StatusForbidden, rr.Code)
+			assertEqual(t, 0, gets)
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+const (
+	bulkRequestSchema  = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	bulkResponseSchema = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+
+	// bulkIDPrefix is the prefix used to reference a resource created within the same bulk request.
+	bulkIDPrefix = "bulkId:"
+)
+
+// bulkIDReference matches a reference to a bulk identifier, e.g., "bulkId:qwerty". References can be part of a larger
+// string, e.g., a PATCH path like `members[value eq "bulkId:qwerty"]`, so the identifier ends at the first character
+// that delimits values in paths, filters and URLs.
+var bulkIDReference = regexp.MustCompile(bulkIDPrefix + `([^\s"'/,()\[\]]+)`)
+
+// bulkIDReferences returns all the bulk identifiers that are referenced by given value.
+func bulkIDReferences(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		var references []string
+		for _, match := range bulkIDReference.FindAllStringSubmatch(v, -1) {
+			references = append(references, match[1])
+		}
+		return references
+	case []interface{}:
+		var references []string
+		for _, e := range v {
+			references = append(references, bulkIDReferences(e)...)
+		}
+		return references
+	case map[string]interface{}:
+		var references []string
+		for _, e := range v {
+			references = append(references, bulkIDReferences(e)...)
+		}
+		return references
+	}
+	return nil
+}
+
+// replaceBulkIDs replaces all bulk identifier references within given value with their resolved resource identifiers.
+func replaceBulkIDs(v interface{}, ids map[string]string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return bulkIDReference.ReplaceAllStringFunc(v, func(reference string) string {
+			if id, ok := ids[strings.TrimPrefix(reference, bulkIDPrefix)]; ok {
+				return id
+			}
+			return reference
+		})
+	case []interface{}:
+		for i, e := range v {
+			v[i] = replaceBulkIDs(e, ids)
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = replaceBulkIDs(e, ids)
+		}
+	}
+	return v
+}
+
+// bulkOperation represents a single operation within a bulk request.
+type bulkOperation struct {
+	// Method is the HTTP method of the current operation.
+	Method string
+	// BulkID is a transient identifier of a newly created resource, unique within a bulk request.
+	BulkID string
+	// Version is the current resource version.
+	Version string
+	// Path is the resource's relative path to the SCIM service provider's root.
+	Path string
+	// Data is the resource data as it would appear for a single SCIM POST, PUT, or PATCH operation.
+	Data json.RawMessage
+
+	// data is the parsed version of Data, nil if there is no (valid) data.
+	data interface{}
+}
+
+// references returns all the bulk identifiers that are referenced by the operation, both in the path and the data.
+func (op bulkOperation) references() []string {
+	return append(bulkIDReferences(op.Path), bulkIDReferences(op.data)...)
+}
+
+// bulkOperationResponse represents the result of a single operation within a bulk response.
+type bulkOperationResponse struct {
+	Method   string
+	BulkID   string
+	Version  string
+	Location string
+	Status   int
+	Response interface{}
+}
+
+func newBulkOperationErrorResponse(op bulkOperation, scimErr errors.ScimError) bulkOperationResponse {
+	return bulkOperationResponse{
+		Method:   op.Method,
+		BulkID:   op.BulkID,
+		Status:   scimErr.Status,
+		Response: scimErr,
+	}
+}
+
+func (o bulkOperationResponse) MarshalJSON() ([]byte, error) {
+	response := map[string]interface{}{
+		"method": o.Method,
+		"status": strconv.Itoa(o.Status),
+	}
+	if o.BulkID != "" {
+		response["bulkId"] = o.BulkID
+	}
+	if o.Version != "" {
+		response["version"] = o.Version
+	}
+	if o.Location != "" {
+		response["location"] = o.Location
+	}
+	if o.Response != nil {
+		response["response"] = o.Response
+	}
+	return json.Marshal(response)
+}
+
+// bulkResponse identifies a bulk response.
+type bulkResponse struct {
+	// Operations contains the results of all the processed operations.
+	Operations []bulkOperationResponse
+}
+
+func (b bulkResponse) MarshalJSON() ([]byte, error) {
+	operations := b.Operations
+	if operations == nil {
+		operations = make([]bulkOperationResponse, 0)
+	}
+	return json.Marshal(map[string]interface{}{
+		"schemas":    []string{bulkResponseSchema},
+		"Operations": operations,
+	})
+}
+
+// bulkResponseWriter captures the response of a single bulk operation.
+type bulkResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBulkResponseWriter() *bulkResponseWriter {
+	return &bulkResponseWriter{
+		header: make(http.Header),
+	}
+}
+
+func (w *bulkResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bulkResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *bulkResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// bulkHandler receives an HTTP POST request to the bulk endpoint, "/Bulk", to apply operations to a large collection
+// of resources in a single request. Operations may reference resources created within the same request by their
+// "bulkId", even if these are created by a later operation.
+func (s Server) bulkHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.Config.SupportBulk {
+		errorHandler(w, r, &errors.ScimError{
+			Status: http.StatusNotImplemented,
+		})
+		return
+	}
+
+	maxPayloadSize := s.Config.getBulkMaxPayloadSize()
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(maxPayloadSize)+1))
+	if err != nil {
+		errorHandler(w, r, &errors.ScimErrorInvalidSyntax)
+		return
+	}
+	if len(data) > maxPayloadSize {
+		scimErr := errors.ScimErrorRequestEntityTooLarge(fmt.Sprintf(
+			"The size of the bulk operation exceeds the maxPayloadSize (%d).", maxPayloadSize,
+		))
+		errorHandler(w, r, &scimErr)
+		return
+	}
+
+	var req struct {
+		Schemas      []string
+		FailOnErrors int
+		Operations   []bulkOperation
+	}
+	if err := unmarshal(data, &req); err != nil {
+		errorHandler(w, r, &errors.ScimErrorInvalidSyntax)
+		return
+	}
+
+	// The body of each request MUST contain the "schemas" attribute with the URI value of
+	// "urn:ietf:params:scim:api:messages:2.0:BulkRequest".
+	if len(req.Schemas) != 1 || req.Schemas[0] != bulkRequestSchema {
+		errorHandler(w, r, &errors.ScimErrorInvalidValue)
+		return
+	}
+
+	if len(req.Operations) < 1 {
+		errorHandler(w, r, &errors.ScimErrorInvalidValue)
+		return
+	}
+	if maxOperations := s.Config.getBulkMaxOperations(); len(req.Operations) > maxOperations {
+		scimErr := errors.ScimErrorRequestEntityTooLarge(fmt.Sprintf(
+			"The number of operations exceeds the maxOperations (%d).", maxOperations,
+		))
+		errorHandler(w, r, &scimErr)
+		return
+	}
+
+	// The bulk identifiers are unique within a bulk request, otherwise references to them would be ambiguous.
+	bulkIDs := make(map[string]bool)
+	for _, op := range req.Operations {
+		if op.BulkID == "" {
+			continue
+		}
+		if bulkIDs[op.BulkID] {
+			errorHandler(w, r, &errors.ScimError{
+				ScimType: errors.ScimTypeInvalidValue,
+				Detail:   fmt.Sprintf("Duplicate bulkId %q.", op.BulkID),
+				Status:   http.StatusBadRequest,
+			})
+			return
+		}
+		bulkIDs[op.BulkID] = true
+	}
+
+	raw, err := json.Marshal(bulkResponse{
+		Operations: s.processBulkOperations(r, req.Operations, req.FailOnErrors),
+	})
+	if err != nil {
+		logError(r, "failed marshaling bulk response", err)
+		errorHandler(w, r, &errors.ScimErrorInternal)
+		return
+	}
+
+	_, err = w.Write(raw)
+	if err != nil {
+		logError(r, "failed writing response", err)
+	}
+}
+
+// processBulkOperations processes the given operations in order. Operations that reference a bulk identifier of a
+// resource that is not yet created are deferred until that resource is created. Operations whose references can not
+// be resolved, e.g. because of a circular reference, result in a 409 (Conflict). Processing stops after the number of
+// errors reaches failOnErrors, unless it is zero. The responses of the processed operations are returned in the order
+// of the operations in the request, regardless of the order in which they were processed.
+func (s Server) processBulkOperations(r *http.Request, operations []bulkOperation, failOnErrors int) []bulkOperationResponse {
+	definedBy := make(map[string]int)
+	for i, op := range operations {
+		op.Method = strings.ToUpper(op.Method)
+		if len(op.Data) != 0 {
+			_ = unmarshal(op.Data, &op.data)
+		}
+		operations[i] = op
+		if op.BulkID != "" && op.Method == http.MethodPost {
+			definedBy[op.BulkID] = i
+		}
+	}
+
+	var (
+		errCount int
+
+		// ids maps the bulk identifiers to the identifiers of the created resources.
+		ids = make(map[string]string)
+		// processed keeps track of the responses of the operations that were already processed.
+		processed = make(map[int]bulkOperationResponse)
+		pending   = make([]int, len(operations))
+	)
+	for i := range operations {
+		pending[i] = i
+	}
+
+	// responses returns the responses of the processed operations in request order.
+	responses := func() []bulkOperationResponse {
+		var ordered []bulkOperationResponse
+		for i := range operations {
+			if response, ok := processed[i]; ok {
+				ordered = append(ordered, response)
+			}
+		}
+		return ordered
+	}
+
+	// addResponse adds the response of a processed operation and reports whether processing should stop.
+	addResponse := func(i int, response bulkOperationResponse) bool {
+		processed[i] = response
+		if response.Status >= http.StatusBadRequest {
+			errCount++
+		}
+		return failOnErrors > 0 && errCount >= failOnErrors
+	}
+
+	for len(pending) != 0 {
+		var deferred []int
+		for _, i := range pending {
+			op := operations[i]
+
+			var (
+				unresolved string
+				wait       bool
+			)
+			for _, ref := range op.references() {
+				if _, ok := ids[ref]; ok {
+					continue
+				}
+				if j, ok := definedBy[ref]; ok && j != i {
+					if _, done := processed[j]; !done {
+						wait = true
+						continue
+					}
+				}
+				unresolved = ref
+				break
+			}
+
+			var response bulkOperationResponse
+			switch {
+			case unresolved != "":
+				response = newBulkOperationErrorResponse(op, errors.ScimError{
+					ScimType: errors.ScimTypeInvalidValue,
+					Detail:   fmt.Sprintf("Unable to resolve bulkId %q.", unresolved),
+					Status:   http.StatusConflict,
+				})
+			case wait:
+				deferred = append(deferred, i)
+				continue
+			default:
+				response = s.bulkOperationHandler(r, op, ids)
+			}
+			if addResponse(i, response) {
+				return responses()
+			}
+		}
+
+		// None of the deferred operations can be processed, so they reference each other.
+		if len(deferred) == len(pending) {
+			for _, i := range deferred {
+				op := operations[i]
+				if addResponse(i, newBulkOperationErrorResponse(op, errors.ScimError{
+					ScimType: errors.ScimTypeInvalidValue,
+					Detail:   "Circular reference detected between bulk operations.",
+					Status:   http.StatusConflict,
+				})) {
+					return responses()
+				}
+			}
+			break
+		}
+		pending = deferred
+	}
+	return responses()
+}
+
+// bulkOperationHandler processes a single bulk operation by dispatching it to the corresponding resource type. All
+// (resolved) bulk identifier references are replaced by their resource identifier before doing so.
+func (s Server) bulkOperationHandler(r *http.Request, op bulkOperation, ids map[string]string) bulkOperationResponse {
+	switch op.Method {
+	case http.MethodPost:
+		// The bulkId is REQUIRED when the method is "POST".
+		if op.BulkID == "" {
+			return newBulkOperationErrorResponse(op, errors.ScimErrorInvalidSyntax)
+		}
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return newBulkOperationErrorResponse(op, errors.ScimErrorBadRequest(
+			fmt.Sprintf("Bad Request. Invalid bulk operation method: %s.", op.Method),
+		))
+	}
+
+	path := replaceBulkIDs(op.Path, ids).(string)
+
+	data := []byte(op.Data)
+	if op.data != nil {
+		if raw, err := json.Marshal(replaceBulkIDs(op.data, ids)); err == nil {
+			data = raw
+		}
+	}
+
+	// The bulk operations do not change the log record of the bulk request itself.
+	req := r.Clone(detachRequestLog(r.Context()))
+	req.Method = op.Method
+	req.URL.Path = path
+	req.URL.RawPath = ""
+	req.URL.RawQuery = ""
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.Header.Del("If-Match")
+	if op.Version != "" {
+		req.Header.Set("If-Match", op.Version)
+	}
+
+	rw := newBulkResponseWriter()
+	if !s.serveResource(rw, req, path) {
+		errorHandler(rw, req, &errors.ScimError{
+			Detail: "Specified endpoint does not exist.",
+			Status: http.StatusNotFound,
+		})
+	}
+
+	response := bulkOperationResponse{
+		Method:  op.Method,
+		BulkID:  op.BulkID,
+		Version: rw.header.Get("Etag"),
+		Status:  rw.status,
+	}
+	if response.Status >= http.StatusBadRequest {
+		response.Response = json.RawMessage(rw.body.Bytes())
+		return response
+	}
+
+	location := path
+	if op.Method == http.MethodPost {
+		var resource map[string]interface{}
+		if err := unmarshal(rw.body.Bytes(), &resource); err == nil {
+			if id, ok := resource["id"].(string); ok {
+				ids[op.BulkID] = id
+				location = fmt.Sprintf("%s/%s", location, id)
+			}
+		}
+	}
+	if op.Method != http.MethodDelete {
+		response.Location = s.location(r, location)
+	}
+	return response
+}
@@ -0,0 +1,311 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+func TestServerBulkHandlerCircularReference(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportBulk = true
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{
+				"method": "POST",
+				"path": "/Groups",
+				"bulkId": "group1",
+				"data": {"displayName": "Group 1", "members": [{"value": "bulkId:group2"}]}
+			},
+			{
+				"method": "POST",
+				"path": "/Groups",
+				"bulkId": "group2",
+				"data": {"displayName": "Group 2", "members": [{"value": "bulkId:group1"}]}
+			}
+		]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Operations []map[string]interface{}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertLen(t, response.Operations, 2)
+	for _, op := range response.Operations {
+		assertEqual(t, "409", op["status"])
+	}
+}
+
+func TestServerBulkHandlerFailOnErrors(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportBulk = true
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"failOnErrors": 1,
+		"Operations": [
+			{
+				"method": "DELETE",
+				"path": "/Users/9999"
+			},
+			{
+				"method": "DELETE",
+				"path": "/Users/0001"
+			}
+		]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Operations []map[string]interface{}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertLen(t, response.Operations, 1)
+	assertEqual(t, "404", response.Operations[0]["status"])
+	assertNotNil(t, response.Operations[0]["response"], "response")
+}
+
+func TestServerBulkHandlerForwardReference(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportBulk = true
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{
+				"method": "POST",
+				"path": "/Groups",
+				"bulkId": "ytrewq",
+				"data": {
+					"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+					"displayName": "Tour Guides",
+					"members": [{"type": "User", "value": "bulkId:qwerty"}]
+				}
+			},
+			{
+				"method": "POST",
+				"path": "/Users",
+				"bulkId": "qwerty",
+				"data": {
+					"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+					"userName": "Alice"
+				}
+			}
+		]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Schemas    []string
+		Operations []map[string]interface{}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertEqualStrings(t, []string{"urn:ietf:params:scim:api:messages:2.0:BulkResponse"}, response.Schemas)
+	assertLen(t, response.Operations, 2)
+
+	// The user gets created first, since the group references it, but the responses are in request order.
+	group, user := response.Operations[0], response.Operations[1]
+	assertEqual(t, "qwerty", user["bulkId"])
+	assertEqual(t, "201", user["status"])
+	assertEqual(t, "ytrewq", group["bulkId"])
+	assertEqual(t, "201", group["status"])
+
+	userLocation, ok := user["location"].(string)
+	assertTypeOk(t, ok, "string")
+	groupLocation, ok := group["location"].(string)
+	assertTypeOk(t, ok, "string")
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+groupLocation, nil))
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var resource map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+	members, ok := resource["members"].([]interface{})
+	assertTypeOk(t, ok, "array")
+	assertLen(t, members, 1)
+	member, ok := members[0].(map[string]interface{})
+	assertTypeOk(t, ok, "object")
+	assertEqual(t, strings.TrimPrefix(userLocation, "Users/"), member["value"])
+}
+
+func TestServerBulkHandlerMaxOperations(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportBulk = true
+	s.Config.BulkMaxOperations = 1
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "DELETE", "path": "/Users/0001"},
+			{"method": "DELETE", "path": "/Users/0002"}
+		]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestServerBulkHandlerMaxPayloadSize(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportBulk = true
+	s.Config.BulkMaxPayloadSize = 16
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [{"method": "DELETE", "path": "/Users/0001"}]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestServerBulkHandlerNotSupported(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [{"method": "DELETE", "path": "/Users/0001"}]
+	}`))
+	rr := httptest.NewRecorder()
+	newTestServer().ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusNotImplemented, rr.Code)
+}
+
+func TestServerBulkHandlerUnknownBulkID(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportBulk = true
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{
+				"method": "PUT",
+				"path": "/Users/bulkId:unknown",
+				"data": {"userName": "Bob"}
+			}
+		]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Operations []map[string]interface{}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertLen(t, response.Operations, 1)
+	assertEqual(t, "409", response.Operations[0]["status"])
+}
+
+func TestServerBulkHandlerDuplicateBulkID(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportBulk = true
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "POST", "path": "/Users", "bulkId": "qwerty", "data": {"userName": "Alice"}},
+			{"method": "POST", "path": "/Users", "bulkId": "qwerty", "data": {"userName": "Bob"}}
+		]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+
+	var scimErr errors.ScimError
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &scimErr))
+	assertEqual(t, errors.ScimTypeInvalidValue, scimErr.ScimType)
+}
+
+// testPatchPathsHandler is a resource handler that records the paths of the PATCH operations.
+type testPatchPathsHandler struct {
+	ResourceHandler
+	paths *[]string
+}
+
+func (h testPatchPathsHandler) Patch(r *http.Request, id string, operations []PatchOperation) (Resource, error) {
+	for _, op := range operations {
+		*h.paths = append(*h.paths, op.Path.String())
+	}
+	return h.Get(r, id)
+}
+
+func TestServerBulkHandlerReferenceInPath(t *testing.T) {
+	var paths []string
+	s := newTestServer()
+	s.Config.SupportBulk = true
+	s.ResourceTypes[2].Handler = testPatchPathsHandler{ResourceHandler: s.ResourceTypes[2].Handler, paths: &paths}
+
+	// The group only references the user within the filter of its PATCH path.
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{
+				"method": "PATCH",
+				"path": "/Groups/0001",
+				"data": {
+					"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+					"Operations": [{"op": "remove", "path": "members[value eq \"bulkId:qwerty\"]"}]
+				}
+			},
+			{
+				"method": "POST",
+				"path": "/Users",
+				"bulkId": "qwerty",
+				"data": {"userName": "Alice"}
+			}
+		]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Operations []map[string]interface{}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertLen(t, response.Operations, 2)
+
+	// The user gets created first, since the group references it, but the responses are in request order.
+	group, user := response.Operations[0], response.Operations[1]
+	assertEqual(t, "201", user["status"])
+	assertEqual(t, "200", group["status"])
+
+	location, ok := user["location"].(string)
+	assertTypeOk(t, ok, "string")
+	assertEqualStrings(t, []string{
+		`members[value eq "` + strings.TrimPrefix(location, "Users/") + `"]`,
+	}, paths)
+}
+
+func TestBulkIDReferences(t *testing.T) {
+	for _, test := range []struct {
+		value      interface{}
+		references []string
+	}{
+		{value: "bulkId:qwerty", references: []string{"qwerty"}},
+		{value: "/Groups/bulkId:qwerty", references: []string{"qwerty"}},
+		{value: `members[value eq "bulkId:qwerty" or value eq "bulkId:ytrewq"].display`, references: []string{"qwerty", "ytrewq"}},
+		{value: []interface{}{map[string]interface{}{"value": "bulkId:qwerty"}}, references: []string{"qwerty"}},
+		{value: "qwerty"},
+	} {
+		assertEqualStrings(t, test.references, bulkIDReferences(test.value))
+	}
+
+	replaced := replaceBulkIDs(`members[value eq "bulkId:qwerty" or value eq "bulkId:unknown"]`, map[string]string{"qwerty": "0001"})
+	assertEqual(t, `members[value eq "0001" or value eq "bulkId:unknown"]`, replaced)
+}
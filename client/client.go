@@ -0,0 +1,176 @@
+// Package client implements a client for SCIM 2.0 service providers, as defined in RFC 7644. It reuses the schemas of
+// the schema package to validate filters and PATCH paths, and returns the errors of the service provider as an
+// errors.ScimError.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/schema"
+)
+
+// contentType is the media type of the SCIM requests and responses.
+const contentType = "application/scim+json"
+
+// Authorizer adds the credentials of the client to the requests, e.g., an "Authorization" header.
+type Authorizer interface {
+	// Authorize adds the credentials to the given request.
+	Authorize(r *http.Request) error
+}
+
+// AuthorizerFunc is an adapter to use a function as an authorizer.
+type AuthorizerFunc func(r *http.Request) error
+
+// Authorize calls f(r).
+func (f AuthorizerFunc) Authorize(r *http.Request) error {
+	return f(r)
+}
+
+// BasicAuth returns an authorizer that uses HTTP Basic authentication with the given username and password.
+func BasicAuth(username, password string) Authorizer {
+	return AuthorizerFunc(func(r *http.Request) error {
+		r.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// BearerToken returns an authorizer that uses the given OAuth 2.0 bearer token.
+func BearerToken(token string) Authorizer {
+	return AuthorizerFunc(func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// Client is a client of a SCIM service provider.
+type Client struct {
+	// BaseURL is the base URL of the service provider, e.g., "https://example.com/scim/v2".
+	BaseURL string
+	// HTTPClient is the HTTP client that sends the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Authorizer adds the credentials to the requests, if any. See BearerToken and BasicAuth.
+	Authorizer Authorizer
+}
+
+// Resources returns a client for the resources at the given endpoint, e.g., "/Users", of which the attributes are
+// defined by the given schema and schema extensions.
+func (c Client) Resources(endpoint string, s schema.Schema, extensions ...schema.Schema) ResourceClient {
+	return ResourceClient{
+		client:     c,
+		endpoint:   endpoint,
+		schema:     s,
+		extensions: extensions,
+	}
+}
+
+// Schema returns the schema with the given id that is supported by the service provider.
+func (c Client) Schema(ctx context.Context, id string) (schema.Schema, error) {
+	var s schema.Schema
+	err := c.do(ctx, http.MethodGet, "/Schemas/"+url.PathEscape(id), nil, &s)
+	return s, err
+}
+
+// Schemas returns the schemas that are supported by the service provider.
+func (c Client) Schemas(ctx context.Context) ([]schema.Schema, error) {
+	var response struct {
+		Resources []schema.Schema
+	}
+	if err := c.do(ctx, http.MethodGet, "/Schemas", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Resources, nil
+}
+
+// Search searches the resources of all resource types of the service provider with a POST request to "/.search".
+func (c Client) Search(ctx context.Context, params ListParams) (ListResponse, error) {
+	var response ListResponse
+	err := c.do(ctx, http.MethodPost, "/.search", params.searchRequest(), &response)
+	return response, err
+}
+
+// do sends a request with the given method, path (relative to the base URL) and JSON body. The JSON body of the
+// response is decoded into the given value, if not nil. Error responses are returned as an errors.ScimError.
+func (c Client) do(ctx context.Context, method, path string, body, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Accept", contentType)
+	if body != nil {
+		r.Header.Set("Content-Type", contentType)
+	}
+	if c.Authorizer != nil {
+		if err := c.Authorizer.Authorize(r); err != nil {
+			return err
+		}
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp.StatusCode, raw)
+	}
+	if v == nil || len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+	if err := unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid response of %s %s: %v", method, path, err)
+	}
+	return nil
+}
+
+// decodeError returns the SCIM error of an error response with the given status code and body. Responses that do not
+// contain a SCIM error, e.g., of a proxy, are returned as a SCIM error with the body as detail.
+func decodeError(status int, body []byte) errors.ScimError {
+	var scimErr errors.ScimError
+	if err := json.Unmarshal(body, &scimErr); err == nil && scimErr.Status != 0 {
+		return scimErr
+	}
+
+	detail := strings.TrimSpace(string(body))
+	if detail == "" {
+		detail = http.StatusText(status)
+	}
+	return errors.ScimError{
+		Detail: detail,
+		Status: status,
+	}
+}
+
+// unmarshal decodes the given JSON data into the given value. Numbers are represented as a json.Number, like the
+// attributes that are passed to the resource handlers of the server.
+func unmarshal(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
@@ -0,0 +1,327 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/errors"
+	f "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+const enterpriseUserSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+// testResourceHandler is an in-memory resource handler.
+type testResourceHandler struct {
+	data map[string]scim.ResourceAttributes
+}
+
+func (h testResourceHandler) Create(r *http.Request, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	id := fmt.Sprintf("%04d", len(h.data)+1)
+	h.data[id] = attributes
+	return h.resource(id), nil
+}
+
+func (h testResourceHandler) Delete(r *http.Request, id string) error {
+	if _, ok := h.data[id]; !ok {
+		return errors.ScimErrorResourceNotFound(id)
+	}
+	delete(h.data, id)
+	return nil
+}
+
+func (h testResourceHandler) Get(r *http.Request, id string) (scim.Resource, error) {
+	if _, ok := h.data[id]; !ok {
+		return scim.Resource{}, errors.ScimErrorResourceNotFound(id)
+	}
+	return h.resource(id), nil
+}
+
+func (h testResourceHandler) GetAll(r *http.Request, params scim.ListRequestParams) (scim.Page, error) {
+	page := scim.Page{Resources: []scim.Resource{}}
+	for id, attributes := range h.data {
+		if params.Filter != nil {
+			validator := f.NewFilterValidator(params.Filter, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+			if err := validator.PassesFilter(attributes); err != nil {
+				continue
+			}
+		}
+		page.Resources = append(page.Resources, h.resource(id))
+	}
+	page.TotalResults = len(page.Resources)
+	return page, nil
+}
+
+func (h testResourceHandler) Patch(r *http.Request, id string, operations []scim.PatchOperation) (scim.Resource, error) {
+	attributes, ok := h.data[id]
+	if !ok {
+		return scim.Resource{}, errors.ScimErrorResourceNotFound(id)
+	}
+	patched, _, err := scim.ApplyPatch(attributes, operations, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	h.data[id] = patched
+	return h.resource(id), nil
+}
+
+func (h testResourceHandler) Replace(r *http.Request, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	if _, ok := h.data[id]; !ok {
+		return scim.Resource{}, errors.ScimErrorResourceNotFound(id)
+	}
+	h.data[id] = attributes
+	return h.resource(id), nil
+}
+
+func (h testResourceHandler) resource(id string) scim.Resource {
+	attributes := make(scim.ResourceAttributes)
+	for k, v := range h.data[id] {
+		attributes[k] = v
+	}
+	var externalID optional.String
+	if v, ok := attributes[schema.CommonAttributeExternalID].(string); ok {
+		externalID = optional.NewString(v)
+		delete(attributes, schema.CommonAttributeExternalID)
+	}
+	return scim.Resource{
+		ID:         id,
+		ExternalID: externalID,
+		Attributes: attributes,
+		Meta:       scim.Meta{Version: "v" + id},
+	}
+}
+
+func newTestClient(t *testing.T) Client {
+	s := scim.Server{
+		Config: scim.ServiceProviderConfig{
+			SupportFiltering: true,
+			SupportPatch:     true,
+		},
+		Authenticators: []scim.Authenticator{
+			scim.BearerTokenAuthenticator{
+				Verify: func(_ context.Context, token string) (scim.Principal, error) {
+					if token != "secret" {
+						return scim.Principal{}, fmt.Errorf("invalid token")
+					}
+					return scim.Principal{ID: "client"}, nil
+				},
+			},
+		},
+		ResourceTypes: []scim.ResourceType{
+			{
+				ID:       optional.NewString("User"),
+				Name:     "User",
+				Endpoint: "/Users",
+				Schema:   schema.CoreUserSchema(),
+				SchemaExtensions: []scim.SchemaExtension{
+					{Schema: schema.ExtensionEnterpriseUser()},
+				},
+				Handler: testResourceHandler{data: make(map[string]scim.ResourceAttributes)},
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.StripPrefix("/scim", s))
+	t.Cleanup(server.Close)
+	return Client{
+		BaseURL:    server.URL + "/scim/v2",
+		HTTPClient: server.Client(),
+		Authorizer: BearerToken("secret"),
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	users := c.Resources("/Users", schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+
+	created, err := users.Create(ctx, map[string]interface{}{
+		"userName":   "bjensen",
+		"externalId": "external",
+		"active":     true,
+		enterpriseUserSchema: map[string]interface{}{
+			"employeeNumber": "42",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "0001", created.ID)
+	assertEqual(t, "external", created.ExternalID)
+	assertEqual(t, "User", created.Meta.ResourceType)
+	assertEqual(t, "v0001", created.Meta.Version)
+	assertDeepEqual(t, []string{"urn:ietf:params:scim:schemas:core:2.0:User", enterpriseUserSchema}, created.Schemas)
+	assertDeepEqual(t, map[string]interface{}{
+		"userName":           "bjensen",
+		"active":             true,
+		enterpriseUserSchema: map[string]interface{}{"employeeNumber": "42"},
+	}, created.Attributes)
+
+	if _, err := users.Create(ctx, map[string]interface{}{"userName": "jsmith"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := users.Get(ctx, "0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, created, got)
+
+	list, err := users.List(ctx, ListParams{Filter: `userName eq "jsmith"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, 1, list.TotalResults)
+	assertEqual(t, 1, len(list.Resources))
+	assertEqual(t, "0002", list.Resources[0].ID)
+
+	search, err := users.Search(ctx, ListParams{
+		Filter:     `userName eq "bjensen"`,
+		Attributes: []string{"userName"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, 1, search.TotalResults)
+	assertDeepEqual(t, map[string]interface{}{"userName": "bjensen"}, search.Resources[0].Attributes)
+
+	all, err := c.Search(ctx, ListParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, 2, all.TotalResults)
+
+	patched, err := users.Patch(ctx, "0001", []PatchOperation{
+		{Op: "replace", Path: "active", Value: false},
+		{Op: "add", Path: "displayName", Value: "Babs Jensen"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, false, patched.Attributes["active"])
+	assertEqual(t, "Babs Jensen", patched.Attributes["displayName"])
+
+	replaced, err := users.Replace(ctx, "0001", map[string]interface{}{"userName": "bjensen2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, map[string]interface{}{"userName": "bjensen2"}, replaced.Attributes)
+
+	if err := users.Delete(ctx, "0001"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = users.Get(ctx, "0001")
+	var scimErr errors.ScimError
+	if !stderrors.As(err, &scimErr) {
+		t.Fatalf("expected a SCIM error, got %v", err)
+	}
+	assertEqual(t, http.StatusNotFound, scimErr.Status)
+	assertEqual(t, "Resource 0001 not found.", scimErr.Detail)
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	users := c.Resources("/Users", schema.CoreUserSchema())
+
+	for _, test := range []struct {
+		name             string
+		do               func(c Client) error
+		expectedStatus   int
+		expectedScimType errors.ScimType
+	}{
+		{
+			name: "unauthorized",
+			do: func(c Client) error {
+				c.Authorizer = BasicAuth("client", "secret")
+				_, err := c.Resources("/Users", schema.CoreUserSchema()).Get(ctx, "0001")
+				return err
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "invalid value",
+			do: func(c Client) error {
+				_, err := users.Create(ctx, map[string]interface{}{"userName": 42})
+				return err
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedScimType: errors.ScimTypeInvalidValue,
+		},
+		{
+			name: "not a SCIM response",
+			do: func(c Client) error {
+				_, err := c.Resources("/Unknown", schema.CoreUserSchema()).Get(ctx, "0001")
+				return err
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.do(c)
+			var scimErr errors.ScimError
+			if !stderrors.As(err, &scimErr) {
+				t.Fatalf("expected a SCIM error, got %v", err)
+			}
+			assertEqual(t, test.expectedStatus, scimErr.Status)
+			assertEqual(t, test.expectedScimType, scimErr.ScimType)
+		})
+	}
+
+	// Invalid filters and paths are not sent to the service provider.
+	if _, err := users.List(ctx, ListParams{Filter: `unknown eq "value"`}); err == nil {
+		t.Error("expected an error for an invalid filter")
+	}
+	if _, err := users.Patch(ctx, "0001", []PatchOperation{{Op: "remove", Path: "unknown"}}); err == nil {
+		t.Error("expected an error for an invalid path")
+	}
+}
+
+func TestClientSchemas(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	schemas, err := c.Schemas(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, 2, len(schemas))
+
+	s, err := c.Schema(ctx, enterpriseUserSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDeepEqual(t, schema.ExtensionEnterpriseUser(), s)
+}
+
+func TestDecodeError(t *testing.T) {
+	raw, err := json.Marshal(errors.ScimErrorInvalidFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, errors.ScimErrorInvalidFilter, decodeError(http.StatusBadRequest, raw))
+	assertEqual(t, errors.ScimError{Status: http.StatusBadGateway, Detail: "upstream failed"}, decodeError(http.StatusBadGateway, []byte("upstream failed\n")))
+	assertEqual(t, errors.ScimError{Status: http.StatusBadGateway, Detail: "Bad Gateway"}, decodeError(http.StatusBadGateway, nil))
+}
+
+func assertDeepEqual(t *testing.T, expected, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("not equal: expected %v, actual %v", expected, actual)
+	}
+}
+
+func assertEqual(t *testing.T, expected, actual interface{}) {
+	t.Helper()
+	if expected != actual {
+		t.Errorf("not equal: expected %v, actual %v", expected, actual)
+	}
+}
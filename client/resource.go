@@ -0,0 +1,174 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elimity-com/scim/schema"
+)
+
+// ListParams are the parameters of a list or search request.
+type ListParams struct {
+	// Filter is the filter of the resources, e.g., `userName eq "bjensen"`.
+	Filter string
+	// Attributes is the list of attribute paths that overrides the default set of attributes to return.
+	Attributes []string
+	// ExcludedAttributes is the list of attribute paths that are removed from the default set of attributes to return.
+	ExcludedAttributes []string
+	// SortBy is the attribute whose value is used to order the returned resources.
+	SortBy string
+	// SortOrder is the order in which the resources are sorted, "ascending" or "descending".
+	SortOrder string
+	// StartIndex is the 1-based index of the first resource to return. It is omitted if zero.
+	StartIndex int
+	// Count is the maximum number of resources to return. It is omitted if zero.
+	Count int
+}
+
+// ListResponse is the response of a list or search request.
+type ListResponse struct {
+	// TotalResults is the total number of results of the list or search request.
+	TotalResults int `json:"totalResults"`
+	// ItemsPerPage is the number of resources that are returned in the response.
+	ItemsPerPage int `json:"itemsPerPage"`
+	// StartIndex is the 1-based index of the first resource of the response.
+	StartIndex int `json:"startIndex"`
+	// Resources are the returned resources.
+	Resources []Resource `json:"Resources"`
+}
+
+// Meta is the metadata of a resource.
+type Meta struct {
+	// ResourceType is the name of the resource type of the resource.
+	ResourceType string `json:"resourceType"`
+	// Created is the time that the resource was added to the service provider.
+	Created *time.Time `json:"created"`
+	// LastModified is the most recent time that the resource was updated at the service provider.
+	LastModified *time.Time `json:"lastModified"`
+	// Location is the URI of the resource.
+	Location string `json:"location"`
+	// Version is the version of the resource, e.g., to be used in an "If-Match" header.
+	Version string `json:"version"`
+}
+
+// PatchOperation is an operation of a PATCH request.
+type PatchOperation struct {
+	// Op is the operation to perform: "add", "remove" or "replace".
+	Op string `json:"op"`
+	// Path is the attribute path of the target of the operation, e.g., `emails[type eq "work"].value`. It is optional
+	// for "add" and "replace" operations.
+	Path string `json:"path,omitempty"`
+	// Value is the value to be added or replaced.
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Resource is a resource of a service provider.
+type Resource struct {
+	// ID is the unique identifier of the resource.
+	ID string
+	// ExternalID is the identifier of the resource as defined by the provisioning client, if any.
+	ExternalID string
+	// Schemas are the ids of the schemas of the resource.
+	Schemas []string
+	// Attributes are the other attributes of the resource. The attributes of schema extensions are nested under the id
+	// of the extension. Numbers are represented as a json.Number.
+	Attributes map[string]interface{}
+	// Meta is the metadata of the resource.
+	Meta Meta
+}
+
+// UnmarshalJSON decodes the JSON representation of a resource. The common attributes are moved from the attributes to
+// the corresponding fields.
+func (r *Resource) UnmarshalJSON(data []byte) error {
+	var attributes map[string]interface{}
+	if err := unmarshal(data, &attributes); err != nil {
+		return err
+	}
+
+	var common struct {
+		ID         string   `json:"id"`
+		ExternalID string   `json:"externalId"`
+		Schemas    []string `json:"schemas"`
+		Meta       Meta     `json:"meta"`
+	}
+	if err := json.Unmarshal(data, &common); err != nil {
+		return fmt.Errorf("invalid common attributes: %v", err)
+	}
+	for _, name := range []string{
+		schema.CommonAttributeID,
+		schema.CommonAttributeExternalID,
+		schema.CommonAttributeMeta,
+		"schemas",
+	} {
+		delete(attributes, name)
+	}
+
+	*r = Resource{
+		ID:         common.ID,
+		ExternalID: common.ExternalID,
+		Schemas:    common.Schemas,
+		Attributes: attributes,
+		Meta:       common.Meta,
+	}
+	return nil
+}
+
+// query returns the query parameters of a list request with the parameters.
+func (p ListParams) query() url.Values {
+	query := url.Values{}
+	if p.Filter != "" {
+		query.Set("filter", p.Filter)
+	}
+	if p.Attributes != nil {
+		query.Set("attributes", strings.Join(p.Attributes, ","))
+	}
+	if p.ExcludedAttributes != nil {
+		query.Set("excludedAttributes", strings.Join(p.ExcludedAttributes, ","))
+	}
+	if p.SortBy != "" {
+		query.Set("sortBy", p.SortBy)
+	}
+	if p.SortOrder != "" {
+		query.Set("sortOrder", p.SortOrder)
+	}
+	if p.StartIndex != 0 {
+		query.Set("startIndex", strconv.Itoa(p.StartIndex))
+	}
+	if p.Count != 0 {
+		query.Set("count", strconv.Itoa(p.Count))
+	}
+	return query
+}
+
+// searchRequest returns the body of a search request with the parameters.
+func (p ListParams) searchRequest() map[string]interface{} {
+	request := map[string]interface{}{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:SearchRequest"},
+	}
+	if p.Filter != "" {
+		request["filter"] = p.Filter
+	}
+	if p.Attributes != nil {
+		request["attributes"] = p.Attributes
+	}
+	if p.ExcludedAttributes != nil {
+		request["excludedAttributes"] = p.ExcludedAttributes
+	}
+	if p.SortBy != "" {
+		request["sortBy"] = p.SortBy
+	}
+	if p.SortOrder != "" {
+		request["sortOrder"] = p.SortOrder
+	}
+	if p.StartIndex != 0 {
+		request["startIndex"] = p.StartIndex
+	}
+	if p.Count != 0 {
+		request["count"] = p.Count
+	}
+	return request
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	f "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+)
+
+// ResourceClient is a client for the resources of a single resource type, see Client.Resources. The filters and the
+// PATCH paths are validated against the schemas of the resource type before they are sent.
+type ResourceClient struct {
+	client     Client
+	endpoint   string
+	schema     schema.Schema
+	extensions []schema.Schema
+}
+
+// Create creates a resource with the given attributes. The attributes of schema extensions are nested under the id of
+// the extension. The "schemas" attribute is added if it is not present.
+func (c ResourceClient) Create(ctx context.Context, attributes map[string]interface{}) (Resource, error) {
+	var resource Resource
+	err := c.client.do(ctx, http.MethodPost, c.endpoint, c.withSchemas(attributes), &resource)
+	return resource, err
+}
+
+// Delete deletes the resource with the given id.
+func (c ResourceClient) Delete(ctx context.Context, id string) error {
+	return c.client.do(ctx, http.MethodDelete, c.path(id), nil, nil)
+}
+
+// Get returns the resource with the given id.
+func (c ResourceClient) Get(ctx context.Context, id string) (Resource, error) {
+	var resource Resource
+	err := c.client.do(ctx, http.MethodGet, c.path(id), nil, &resource)
+	return resource, err
+}
+
+// List returns a page of the resources with a GET request.
+func (c ResourceClient) List(ctx context.Context, params ListParams) (ListResponse, error) {
+	if err := c.validateFilter(params.Filter); err != nil {
+		return ListResponse{}, err
+	}
+
+	path := c.endpoint
+	if query := params.query().Encode(); query != "" {
+		path += "?" + query
+	}
+	var response ListResponse
+	err := c.client.do(ctx, http.MethodGet, path, nil, &response)
+	return response, err
+}
+
+// Patch applies the given operations to the resource with the given id. If the service provider does not return the
+// updated resource, i.e. a 204 No Content response, the returned resource is empty.
+func (c ResourceClient) Patch(ctx context.Context, id string, operations []PatchOperation) (Resource, error) {
+	for _, operation := range operations {
+		if err := c.validatePath(operation.Path); err != nil {
+			return Resource{}, err
+		}
+	}
+
+	var resource Resource
+	err := c.client.do(ctx, http.MethodPatch, c.path(id), map[string]interface{}{
+		"schemas":    []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": operations,
+	}, &resource)
+	return resource, err
+}
+
+// Replace replaces all the attributes of the resource with the given id. The "schemas" attribute is added if it is not
+// present.
+func (c ResourceClient) Replace(ctx context.Context, id string, attributes map[string]interface{}) (Resource, error) {
+	var resource Resource
+	err := c.client.do(ctx, http.MethodPut, c.path(id), c.withSchemas(attributes), &resource)
+	return resource, err
+}
+
+// Search searches the resources with a POST request to the ".search" endpoint of the resource type.
+func (c ResourceClient) Search(ctx context.Context, params ListParams) (ListResponse, error) {
+	if err := c.validateFilter(params.Filter); err != nil {
+		return ListResponse{}, err
+	}
+
+	var response ListResponse
+	err := c.client.do(ctx, http.MethodPost, c.endpoint+"/.search", params.searchRequest(), &response)
+	return response, err
+}
+
+// path returns the path of the resource with the given id.
+func (c ResourceClient) path(id string) string {
+	return c.endpoint + "/" + url.PathEscape(id)
+}
+
+// referenceExtensions returns the schema extensions and the common attributes (e.g. "meta.lastModified"), to which
+// filters and paths can also refer.
+func (c ResourceClient) referenceExtensions() []schema.Schema {
+	return append([]schema.Schema{{
+		ID:         c.schema.ID,
+		Attributes: schema.CommonAttributes(),
+	}}, c.extensions...)
+}
+
+// validateFilter validates the given filter, if any, against the schemas of the resource type.
+func (c ResourceClient) validateFilter(filter string) error {
+	if strings.TrimSpace(filter) == "" {
+		return nil
+	}
+	validator, err := f.NewValidator(filter, c.schema, c.referenceExtensions()...)
+	if err == nil {
+		err = validator.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid filter %q: %v", filter, err)
+	}
+	return nil
+}
+
+// validatePath validates the given PATCH path, if any, against the schemas of the resource type.
+func (c ResourceClient) validatePath(path string) error {
+	if path == "" {
+		return nil
+	}
+	validator, err := f.NewPathValidator(path, c.schema, c.referenceExtensions()...)
+	if err == nil {
+		err = validator.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %v", path, err)
+	}
+	return nil
+}
+
+// withSchemas returns a copy of the given attributes with the "schemas" attribute, if it is not present. The schemas
+// are the schema of the resource type and the schema extensions that are present in the attributes.
+func (c ResourceClient) withSchemas(attributes map[string]interface{}) map[string]interface{} {
+	if _, ok := attributes["schemas"]; ok {
+		return attributes
+	}
+
+	resource := make(map[string]interface{}, len(attributes)+1)
+	for k, v := range attributes {
+		resource[k] = v
+	}
+	schemas := []string{c.schema.ID}
+	for _, extension := range c.extensions {
+		if _, ok := attributes[extension.ID]; ok {
+			schemas = append(schemas, extension.ID)
+		}
+	}
+	resource["schemas"] = schemas
+	return resource
+}
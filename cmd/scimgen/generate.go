@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/elimity-com/scim/schema"
+)
+
+// generator generates the Go source code of a resource type.
+type generator struct {
+	// constants are the attribute path constants, in order.
+	constants []string
+	// types are the generated type declarations, in order.
+	types []string
+}
+
+// generate returns the formatted Go source code for the resource type with the given Go type name, schema and schema
+// extensions, in the package with the given name.
+func generate(pkg, typeName string, s schema.Schema, extensions []schema.Schema) ([]byte, error) {
+	g := generator{
+		constants: []string{schemaConstant(typeName, s)},
+		// The declaration of the resource type comes first.
+		types: []string{""},
+	}
+
+	fields := g.fields(typeName, typeName+"Attr", "", s.Attributes)
+	for _, extension := range extensions {
+		extensionName := schemaTypeName(extension)
+		g.constants = append(g.constants, schemaConstant(extensionName, extension))
+		g.structType(
+			extensionName, fmt.Sprintf("is the %q schema extension of %s.", extension.ID, typeName),
+			typeName+"Attr"+extensionName, extension.ID+":", extension.Attributes,
+		)
+		fields = append(fields, fmt.Sprintf(
+			"%s *%s `json:%q`",
+			extensionName, extensionName, extension.ID+",omitempty",
+		))
+	}
+	g.types[0] = structDecl(typeName, fmt.Sprintf("is a resource with the %q schema.", s.ID), fields)
+
+	var b strings.Builder
+	b.WriteString("// Code generated by scimgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import (\n\t\"bytes\"\n\t\"encoding/json\"\n\n\t\"github.com/elimity-com/scim\"\n)\n\n")
+
+	fmt.Fprintf(&b, "// Schema ids and attribute paths of %s, usable in filters and PATCH paths.\n", typeName)
+	b.WriteString("const (\n")
+	for _, constant := range g.constants {
+		b.WriteString(constant)
+	}
+	b.WriteString(")\n\n")
+
+	for _, decl := range g.types {
+		b.WriteString(decl)
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, `// %[1]sFromAttributes converts the given resource attributes to a %[1]s.
+func %[1]sFromAttributes(attributes scim.ResourceAttributes) (%[1]s, error) {
+	var v %[1]s
+	raw, err := json.Marshal(attributes)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(raw, &v)
+	return v, err
+}
+
+// ToAttributes converts the %[1]s to resource attributes. Numbers are represented as a json.Number.
+func (v %[1]s) ToAttributes() (scim.ResourceAttributes, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var attributes scim.ResourceAttributes
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	err = d.Decode(&attributes)
+	return attributes, err
+}
+`, typeName)
+
+	return format.Source([]byte(b.String()))
+}
+
+// fields returns the struct fields of the given attributes of the type with the given name. The names of the constants
+// of the attribute paths start with the given constant prefix, and the paths start with the given path prefix, e.g.,
+// the id of a schema extension.
+func (g *generator) fields(typeName, constPrefix, pathPrefix string, attributes schema.Attributes) []string {
+	var fields []string
+	for _, a := range attributes {
+		name := exportedName(a.Name())
+		g.constants = append(g.constants, fmt.Sprintf("%s%s = %q\n", constPrefix, name, pathPrefix+a.Name()))
+
+		typ := g.fieldType(typeName, constPrefix+name, pathPrefix+a.Name()+".", a)
+		tag := a.Name()
+		if !a.Required() {
+			tag += ",omitempty"
+		}
+
+		var field strings.Builder
+		if description := a.Description(); description != "" {
+			field.WriteString(comment(description))
+		}
+		fmt.Fprintf(&field, "%s %s `json:%q`", name, typ, tag)
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// fieldType returns the Go type of the given attribute of the type with the given name. The types of complex
+// attributes are generated as well, with the given prefixes for their sub-attributes. Optional non-string values are
+// pointers, so that they can be omitted.
+func (g *generator) fieldType(typeName, constPrefix, pathPrefix string, a schema.CoreAttribute) string {
+	var typ string
+	switch a.AttributeType() {
+	case "boolean":
+		typ = "bool"
+	case "complex":
+		typ = typeName + exportedName(a.Name())
+		g.structType(
+			typ, fmt.Sprintf("is the %q attribute of %s.", a.Name(), typeName),
+			constPrefix, pathPrefix, a.SubAttributes(),
+		)
+	case "decimal":
+		typ = "float64"
+	case "integer":
+		typ = "int64"
+	default:
+		// Binary, date time and reference values are represented as strings in JSON.
+		typ = "string"
+	}
+
+	switch {
+	case a.MultiValued():
+		return "[]" + typ
+	case a.Required() || typ == "string":
+		return typ
+	default:
+		return "*" + typ
+	}
+}
+
+// structType adds the declaration of the struct type with the given name, documentation and attributes, followed by
+// the declarations of the types of its complex attributes.
+func (g *generator) structType(name, doc, constPrefix, pathPrefix string, attributes schema.Attributes) {
+	i := len(g.types)
+	g.types = append(g.types, "")
+	g.types[i] = structDecl(name, doc, g.fields(name, constPrefix, pathPrefix, attributes))
+}
+
+// comment returns the given text as a line comment, with each line of the text on a separate comment line.
+func comment(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimRight(line, " \t\r"); line == "" {
+			b.WriteString("//\n")
+			continue
+		}
+		fmt.Fprintf(&b, "// %s\n", line)
+	}
+	return b.String()
+}
+
+// exportedName converts the given attribute name to an exported Go identifier, e.g., "$ref" to "Ref".
+func exportedName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 || unicode.IsDigit([]rune(b.String())[0]) {
+		return "X" + b.String()
+	}
+	return b.String()
+}
+
+// schemaConstant returns the constant of the id of the given schema.
+func schemaConstant(typeName string, s schema.Schema) string {
+	return fmt.Sprintf("%sSchemaID = %q\n", typeName, s.ID)
+}
+
+// schemaTypeName returns the default Go type name of the given schema: its name, or otherwise the last segment of its
+// id, e.g., "User" for "urn:ietf:params:scim:schemas:core:2.0:User".
+func schemaTypeName(s schema.Schema) string {
+	if name := s.Name.Value(); name != "" {
+		return exportedName(name)
+	}
+	return exportedName(s.ID[strings.LastIndex(s.ID, ":")+1:])
+}
+
+// structDecl returns the declaration of the struct type with the given name, documentation and fields.
+func structDecl(name, doc string, fields []string) string {
+	return fmt.Sprintf("// %s %s\ntype %s struct {\n%s\n}\n", name, doc, name, strings.Join(fields, "\n"))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		typeName   string
+		schema     schema.Schema
+		extensions []schema.Schema
+		expected   []string
+	}{
+		{
+			name:       "User",
+			typeName:   "User",
+			schema:     schema.CoreUserSchema(),
+			extensions: []schema.Schema{schema.ExtensionEnterpriseUser()},
+			expected: []string{
+				`UserSchemaID = "urn:ietf:params:scim:schemas:core:2.0:User"`,
+				`EnterpriseUserSchemaID = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`,
+				`UserAttrUserName = "userName"`,
+				`UserAttrNameGivenName = "name.givenName"`,
+				`UserAttrGroupsRef = "groups.$ref"`,
+				`UserAttrEnterpriseUserManagerValue = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value"`,
+				"UserName string `json:\"userName\"`",
+				"Name *UserName `json:\"name,omitempty\"`",
+				"Active *bool `json:\"active,omitempty\"`",
+				"Emails []UserEmails `json:\"emails,omitempty\"`",
+				"EnterpriseUser *EnterpriseUser `json:\"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty\"`",
+				"Ref string `json:\"$ref,omitempty\"`",
+				"func UserFromAttributes(attributes scim.ResourceAttributes) (User, error) {",
+				"func (v User) ToAttributes() (scim.ResourceAttributes, error) {",
+			},
+		},
+		{
+			name:     "Group",
+			typeName: "Team",
+			schema:   schema.CoreGroupSchema(),
+			expected: []string{
+				`TeamSchemaID = "urn:ietf:params:scim:schemas:core:2.0:Group"`,
+				`TeamAttrMembersValue = "members.value"`,
+				"DisplayName string `json:\"displayName\"`",
+				"Members []TeamMembers `json:\"members,omitempty\"`",
+				"func TeamFromAttributes(attributes scim.ResourceAttributes) (Team, error) {",
+			},
+		},
+		{
+			name:     "multi-line description",
+			typeName: "Device",
+			schema: schema.Schema{
+				ID: "urn:example:scim:schemas:Device",
+				Attributes: []schema.CoreAttribute{
+					schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+						Name:        "serialNumber",
+						Description: optional.NewString("The serial number of the device.\r\n\nIt is unique per vendor."),
+					})),
+				},
+			},
+			expected: []string{
+				"// The serial number of the device.\n//\n// It is unique per vendor.\nSerialNumber string `json:\"serialNumber,omitempty\"`",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := generate("resources", test.typeName, test.schema, test.extensions)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "resources.go", src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+			if _, err := conf.Check("resources", fset, []*ast.File{file}, nil); err != nil {
+				t.Fatalf("generated code does not compile: %v", err)
+			}
+
+			code := strings.Join(strings.Fields(string(src)), " ")
+			for _, expected := range test.expected {
+				if !strings.Contains(code, strings.Join(strings.Fields(expected), " ")) {
+					t.Errorf("generated code does not contain: %s", expected)
+				}
+			}
+		})
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"userName":         "UserName",
+		"$ref":             "Ref",
+		"x509Certificates": "X509Certificates",
+		"Enterprise User":  "EnterpriseUser",
+		"2fa":              "X2fa",
+	} {
+		if actual := exportedName(name); actual != expected {
+			t.Errorf("expected %q for %q, got %q", expected, name, actual)
+		}
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	builtin, err := loadSchema("User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := loadSchema("../../schema/testdata/user_schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := generate("resources", "User", builtin, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := generate("resources", "User", file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != string(actual) {
+		t.Error("the schema file does not result in the same code as the built-in schema")
+	}
+
+	if _, err := loadSchema("unknown.json"); err == nil {
+		t.Error("expected an error for an unknown schema")
+	}
+}
@@ -0,0 +1,115 @@
+// Command scimgen generates Go types for the resources of a SCIM resource type, based on its schema and its schema
+// extensions. For each schema, it generates a struct with JSON tags, with a struct for each complex attribute. It also
+// generates constants for the schema ids and the attribute paths, which can be used in filters and PATCH paths, and
+// functions to convert the resource to and from scim.ResourceAttributes. The generated types can be used with
+// scim.TypedHandler.
+//
+// The schemas are either one of the built-in schemas "User", "Group" and "EnterpriseUser", or a file with the RFC 7643
+// JSON representation of a schema, e.g., as returned by the "/Schemas" endpoint. It is meant to be used with
+// go generate, e.g.:
+//
+//	//go:generate go run github.com/elimity-com/scim/cmd/scimgen -schema User -extension EnterpriseUser -o user_scim.go
+//
+// Usage:
+//
+//	scimgen -schema <schema> [-extension <schema>]... [-type <name>] [-package <name>] [-o <file>]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/elimity-com/scim/schema"
+)
+
+// builtinSchemas are the schemas that can be referenced by name.
+var builtinSchemas = map[string]func() schema.Schema{
+	"User":           schema.CoreUserSchema,
+	"Group":          schema.CoreGroupSchema,
+	"EnterpriseUser": schema.ExtensionEnterpriseUser,
+}
+
+func main() {
+	var extensions schemaFlags
+	schemaFlag := flag.String("schema", "", `the schema of the resource type: "User", "Group", "EnterpriseUser" or a JSON file`)
+	flag.Var(&extensions, "extension", "a schema extension of the resource type, can be repeated")
+	typeName := flag.String("type", "", "the name of the generated resource type, defaults to the name of the schema")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "the package name, defaults to $GOPACKAGE of go generate")
+	output := flag.String("o", "", "the output file, defaults to the standard output")
+	flag.Parse()
+
+	if err := run(*schemaFlag, extensions, *typeName, *pkg, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "scimgen:", err)
+		os.Exit(1)
+	}
+}
+
+// loadSchema returns the built-in schema with the given name, or otherwise the schema in the JSON file with the given
+// name.
+func loadSchema(name string) (schema.Schema, error) {
+	if builtin, ok := builtinSchemas[name]; ok {
+		return builtin(), nil
+	}
+
+	raw, err := ioutil.ReadFile(name)
+	if err != nil {
+		return schema.Schema{}, err
+	}
+	var s schema.Schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return schema.Schema{}, fmt.Errorf("%s: %v", name, err)
+	}
+	return s, nil
+}
+
+// run generates the resource type with the given schema and extensions, and writes it to the given output file.
+func run(schemaName string, extensionNames []string, typeName, pkg, output string) error {
+	if schemaName == "" {
+		return fmt.Errorf("no schema, use -schema")
+	}
+	if pkg == "" {
+		return fmt.Errorf("no package name, use -package")
+	}
+
+	s, err := loadSchema(schemaName)
+	if err != nil {
+		return err
+	}
+	var extensions []schema.Schema
+	for _, name := range extensionNames {
+		extension, err := loadSchema(name)
+		if err != nil {
+			return err
+		}
+		extensions = append(extensions, extension)
+	}
+	if typeName == "" {
+		typeName = schemaTypeName(s)
+	}
+
+	src, err := generate(pkg, typeName, s, extensions)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(output, src, 0o644)
+}
+
+// schemaFlags is a flag that can be repeated.
+type schemaFlags []string
+
+func (f *schemaFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func (f *schemaFlags) String() string {
+	return strings.Join(*f, ",")
+}
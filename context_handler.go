@@ -0,0 +1,97 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/elimity-com/scim/optional"
+)
+
+// RequestInfo contains the information of the HTTP request that a ContextResourceHandler needs, without depending on
+// net/http request handling.
+type RequestInfo struct {
+	// Method is the HTTP method of the request, e.g., "GET".
+	Method string
+	// Header contains the header fields of the request, e.g., "Authorization".
+	Header http.Header
+	// Query contains the parsed query parameters of the request.
+	Query url.Values
+	// RemoteAddr is the network address of the client that sent the request.
+	RemoteAddr string
+	// Principal is the authenticated client of the request, or nil if the request was not authenticated.
+	Principal *Principal
+	// ExpectedVersion is the version of the resource that the client expects to modify, if the request is conditional.
+	// See ExpectedVersion for more info.
+	ExpectedVersion optional.String
+}
+
+// NewRequestInfo returns the request info of the given HTTP request.
+func NewRequestInfo(r *http.Request) RequestInfo {
+	info := RequestInfo{
+		Method:     r.Method,
+		Header:     r.Header.Clone(),
+		Query:      r.URL.Query(),
+		RemoteAddr: r.RemoteAddr,
+	}
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		info.Principal = &principal
+	}
+	if version, ok := ExpectedVersion(r); ok {
+		info.ExpectedVersion = optional.NewString(version)
+	}
+	return info
+}
+
+// ContextResourceHandler is the context-first alternative of ResourceHandler. The given context is canceled when the
+// client disconnects, so it should be passed to the storage layer. Use AdaptContextHandler to use it as the handler of
+// a resource type. The methods have the same semantics as the corresponding methods of ResourceHandler.
+type ContextResourceHandler interface {
+	// Create stores given attributes. Returns a resource with the attributes that are stored and a (new) unique identifier.
+	Create(ctx context.Context, info RequestInfo, attributes ResourceAttributes) (Resource, error)
+	// Get returns the resource corresponding with the given identifier.
+	Get(ctx context.Context, info RequestInfo, id string) (Resource, error)
+	// GetAll returns a paginated list of resources.
+	GetAll(ctx context.Context, info RequestInfo, params ListRequestParams) (Page, error)
+	// Replace replaces ALL existing attributes of the resource with given identifier.
+	Replace(ctx context.Context, info RequestInfo, id string, attributes ResourceAttributes) (Resource, error)
+	// Delete removes the resource with corresponding ID.
+	Delete(ctx context.Context, info RequestInfo, id string) error
+	// Patch update one or more attributes of a SCIM resource using a sequence of operations.
+	Patch(ctx context.Context, info RequestInfo, id string, operations []PatchOperation) (Resource, error)
+}
+
+// AdaptContextHandler returns a ResourceHandler that passes the context and the info of each request to the given
+// context resource handler.
+func AdaptContextHandler(handler ContextResourceHandler) ResourceHandler {
+	return contextHandlerAdapter{handler: handler}
+}
+
+// contextHandlerAdapter adapts a ContextResourceHandler to a ResourceHandler.
+type contextHandlerAdapter struct {
+	handler ContextResourceHandler
+}
+
+func (a contextHandlerAdapter) Create(r *http.Request, attributes ResourceAttributes) (Resource, error) {
+	return a.handler.Create(r.Context(), NewRequestInfo(r), attributes)
+}
+
+func (a contextHandlerAdapter) Delete(r *http.Request, id string) error {
+	return a.handler.Delete(r.Context(), NewRequestInfo(r), id)
+}
+
+func (a contextHandlerAdapter) Get(r *http.Request, id string) (Resource, error) {
+	return a.handler.Get(r.Context(), NewRequestInfo(r), id)
+}
+
+func (a contextHandlerAdapter) GetAll(r *http.Request, params ListRequestParams) (Page, error) {
+	return a.handler.GetAll(r.Context(), NewRequestInfo(r), params)
+}
+
+func (a contextHandlerAdapter) Patch(r *http.Request, id string, operations []PatchOperation) (Resource, error) {
+	return a.handler.Patch(r.Context(), NewRequestInfo(r), id, operations)
+}
+
+func (a contextHandlerAdapter) Replace(r *http.Request, id string, attributes ResourceAttributes) (Resource, error) {
+	return a.handler.Replace(r.Context(), NewRequestInfo(r), id, attributes)
+}
@@ -0,0 +1,161 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testContextResourceHandler is a context resource handler that stores the info of the last request.
+type testContextResourceHandler struct {
+	info *RequestInfo
+	ctx  *context.Context
+}
+
+func (h testContextResourceHandler) Create(ctx context.Context, info RequestInfo, attributes ResourceAttributes) (Resource, error) {
+	return h.store(ctx, info, Resource{ID: "0001", Attributes: attributes})
+}
+
+func (h testContextResourceHandler) Delete(ctx context.Context, info RequestInfo, id string) error {
+	_, err := h.store(ctx, info, Resource{ID: id})
+	return err
+}
+
+func (h testContextResourceHandler) Get(ctx context.Context, info RequestInfo, id string) (Resource, error) {
+	return h.store(ctx, info, Resource{
+		ID:         id,
+		Attributes: ResourceAttributes{"userName": "test01"},
+		Meta:       Meta{Version: "v1"},
+	})
+}
+
+func (h testContextResourceHandler) GetAll(ctx context.Context, info RequestInfo, params ListRequestParams) (Page, error) {
+	_, err := h.store(ctx, info, Resource{})
+	return Page{}, err
+}
+
+func (h testContextResourceHandler) Patch(ctx context.Context, info RequestInfo, id string, operations []PatchOperation) (Resource, error) {
+	return h.store(ctx, info, Resource{})
+}
+
+func (h testContextResourceHandler) Replace(ctx context.Context, info RequestInfo, id string, attributes ResourceAttributes) (Resource, error) {
+	return h.store(ctx, info, Resource{ID: id, Attributes: attributes})
+}
+
+func (h testContextResourceHandler) store(ctx context.Context, info RequestInfo, resource Resource) (Resource, error) {
+	*h.info, *h.ctx = info, ctx
+	if err := ctx.Err(); err != nil {
+		return Resource{}, err
+	}
+	return resource, nil
+}
+
+func TestAdaptContextHandler(t *testing.T) {
+	var (
+		info RequestInfo
+		ctx  context.Context
+	)
+	s := newTestServer()
+	s.Config.SupportETag = true
+	s.ResourceTypes[0].Handler = AdaptContextHandler(testContextResourceHandler{
+		info: &info,
+		ctx:  &ctx,
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/Users/0001?attributes=userName", strings.NewReader(`{"userName": "test01"}`))
+	req.Header.Set("If-Match", `W/"v1"`)
+	req.Header.Set("X-Request-Id", "1234")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	assertEqual(t, http.MethodPut, info.Method)
+	assertEqual(t, "1234", info.Header.Get("X-Request-Id"))
+	assertEqual(t, "userName", info.Query.Get("attributes"))
+	assertEqual(t, req.RemoteAddr, info.RemoteAddr)
+	assertEqual(t, true, info.ExpectedVersion.Present())
+	assertEqual(t, "v1", info.ExpectedVersion.Value())
+}
+
+func TestAdaptContextHandlerPrincipal(t *testing.T) {
+	var (
+		info RequestInfo
+		ctx  context.Context
+	)
+	s := newTestServer()
+	s.ResourceTypes[0].Handler = AdaptContextHandler(testContextResourceHandler{
+		info: &info,
+		ctx:  &ctx,
+	})
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/Users/0001", nil))
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	if info.Principal != nil {
+		t.Errorf("unexpected principal: %v", *info.Principal)
+	}
+
+	// The principal is passed through the context of the request, e.g., by an authentication middleware.
+	req := httptest.NewRequest(http.MethodGet, "/Users/0001", nil)
+	req = req.WithContext(WithPrincipal(req.Context(), Principal{ID: "client"}))
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	if info.Principal == nil {
+		t.Fatal("missing principal")
+	}
+	assertEqual(t, "client", info.Principal.ID)
+}
+
+func TestAdaptContextHandlerAuthenticatedPrincipal(t *testing.T) {
+	var (
+		info RequestInfo
+		ctx  context.Context
+	)
+	s := newTestServer()
+	s.ResourceTypes[0].Handler = AdaptContextHandler(testContextResourceHandler{
+		info: &info,
+		ctx:  &ctx,
+	})
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{Verify: StaticTokens(map[string]Principal{
+			"secret": {ID: "client"},
+		})},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/Users/0001", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	if info.Principal == nil {
+		t.Fatal("missing principal")
+	}
+	assertEqual(t, "client", info.Principal.ID)
+}
+
+func TestAdaptContextHandlerCanceled(t *testing.T) {
+	var (
+		info       RequestInfo
+		handlerCtx context.Context
+	)
+	s := newTestServer()
+	s.ResourceTypes[0].Handler = AdaptContextHandler(testContextResourceHandler{
+		info: &info,
+		ctx:  &handlerCtx,
+	})
+
+	// The context of the request is canceled if the client disconnects.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/Users/0001", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusInternalServerError, rr.Code)
+	assertEqual(t, context.Canceled, handlerCtx.Err())
+}
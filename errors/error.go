@@ -172,6 +172,30 @@ func ScimErrorBadRequest(msg string) ScimError {
 	}
 }
 
+// ScimErrorForbidden returns an 403 SCIM error with the given message.
+func ScimErrorForbidden(msg string) ScimError {
+	return ScimError{
+		Detail: msg,
+		Status: http.StatusForbidden,
+	}
+}
+
+// ScimErrorPreconditionFailed returns an 412 SCIM error with a detailed message based on the id.
+func ScimErrorPreconditionFailed(id string) ScimError {
+	return ScimError{
+		Detail: fmt.Sprintf("Resource %s has changed on the server.", id),
+		Status: http.StatusPreconditionFailed,
+	}
+}
+
+// ScimErrorRequestEntityTooLarge returns an 413 SCIM error with the given message.
+func ScimErrorRequestEntityTooLarge(msg string) ScimError {
+	return ScimError{
+		Detail: msg,
+		Status: http.StatusRequestEntityTooLarge,
+	}
+}
+
 // ScimErrorResourceNotFound returns an 404 SCIM error with a detailed message based on the id.
 func ScimErrorResourceNotFound(id string) ScimError {
 	return ScimError{
@@ -180,6 +204,14 @@ func ScimErrorResourceNotFound(id string) ScimError {
 	}
 }
 
+// ScimErrorUnauthorized returns an 401 SCIM error with the given message.
+func ScimErrorUnauthorized(msg string) ScimError {
+	return ScimError{
+		Detail: msg,
+		Status: http.StatusUnauthorized,
+	}
+}
+
 func (e ScimError) Error() string {
 	errorMessage := fmt.Sprint(e.Status)
 	if e.ScimType != "" {
@@ -0,0 +1,76 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// expectedVersionKey is the context key of the version that is expected by the client.
+type expectedVersionKey struct{}
+
+// ExpectedVersion returns the version of the resource that the client expects to modify, i.e. the version of the
+// resource that matched the "If-Match" header of a PUT, PATCH or DELETE request. Handlers should compare it with the
+// version in their store when applying the modification, and return a 412 Precondition Failed SCIM error if it does
+// not match anymore. The second return value is false if the request is not conditional.
+// More info: https://datatracker.ietf.org/doc/html/rfc7644#section-3.14
+func ExpectedVersion(r *http.Request) (string, bool) {
+	version, ok := r.Context().Value(expectedVersionKey{}).(string)
+	return version, ok
+}
+
+// withExpectedVersion returns a copy of the given context that contains the given expected version.
+func withExpectedVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// matchesETag reports whether the given version matches one of the entity-tags in the given header value, e.g., the
+// value of the "If-Match" or "If-None-Match" header. Entity-tags are compared using the weak comparison function.
+func matchesETag(header, version string) bool {
+	for _, etag := range strings.Split(header, ",") {
+		etag = strings.TrimSpace(etag)
+		if etag == "*" {
+			return true
+		}
+		if version != "" && opaqueTag(etag) == opaqueTag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// opaqueTag returns the opaque tag of the given entity-tag, without the weak indicator and the surrounding quotes.
+func opaqueTag(etag string) string {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	return strings.Trim(etag, "\"")
+}
+
+// checkPreconditions evaluates the "If-Match" header of the given PUT, PATCH or DELETE request against the current
+// version of the resource with the given identifier. It returns the request that should be passed to the handler,
+// which contains the expected version if the request is conditional.
+func (s Server) checkPreconditions(r *http.Request, id string, resourceType ResourceType) (*http.Request, *errors.ScimError) {
+	ifMatch := r.Header.Get("If-Match")
+	if !s.Config.SupportETag || ifMatch == "" {
+		return r, nil
+	}
+
+	resource, getErr := resourceType.Handler.Get(r, id)
+	if getErr != nil {
+		scimErr := errors.CheckScimError(getErr, r.Method)
+		return r, &scimErr
+	}
+	if !matchesETag(ifMatch, resource.Meta.Version) {
+		scimErr := errors.ScimErrorPreconditionFailed(id)
+		return r, &scimErr
+	}
+	return r.WithContext(withExpectedVersion(r.Context(), resource.Meta.Version)), nil
+}
+
+// notModified reports whether the given version matches the "If-None-Match" header of the given GET request, in which
+// case a 304 Not Modified response should be returned.
+func (s Server) notModified(r *http.Request, version string) bool {
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	return s.Config.SupportETag && ifNoneMatch != "" && matchesETag(ifNoneMatch, version)
+}
@@ -0,0 +1,162 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		header  string
+		version string
+		matches bool
+	}{
+		{header: "v1", version: "v1", matches: true},
+		{header: `"v1"`, version: "v1", matches: true},
+		{header: `W/"v1"`, version: `W/"v1"`, matches: true},
+		{header: `W/"v1"`, version: `"v1"`, matches: true},
+		{header: `"v0", "v1"`, version: "v1", matches: true},
+		{header: "*", version: "v1", matches: true},
+		{header: "*", version: "", matches: true},
+		{header: `"v2"`, version: "v1", matches: false},
+		{header: `""`, version: "", matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			assertEqual(t, test.matches, matchesETag(test.header, test.version))
+		})
+	}
+}
+
+func TestServerResourceDeleteHandlerIfMatch(t *testing.T) {
+	tests := []struct {
+		name               string
+		ifMatch            string
+		expectedStatusCode int
+	}{
+		{name: "matching version", ifMatch: `W/"v1"`, expectedStatusCode: http.StatusNoContent},
+		{name: "any version", ifMatch: "*", expectedStatusCode: http.StatusNoContent},
+		{name: "changed version", ifMatch: `W/"v0"`, expectedStatusCode: http.StatusPreconditionFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer()
+			s.Config.SupportETag = true
+			req := httptest.NewRequest(http.MethodDelete, "/Users/0001", nil)
+			req.Header.Set("If-Match", test.ifMatch)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+		})
+	}
+}
+
+func TestServerResourceDeleteHandlerIfMatchNotSupported(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/Users/0001", nil)
+	req.Header.Set("If-Match", `W/"v0"`)
+	rr := httptest.NewRecorder()
+	newTestServer().ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusNoContent, rr.Code)
+}
+
+func TestServerResourceGetHandlerIfNoneMatch(t *testing.T) {
+	tests := []struct {
+		name               string
+		ifNoneMatch        string
+		expectedStatusCode int
+	}{
+		{name: "matching version", ifNoneMatch: `W/"v1"`, expectedStatusCode: http.StatusNotModified},
+		{name: "changed version", ifNoneMatch: `W/"v0"`, expectedStatusCode: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer()
+			s.Config.SupportETag = true
+			req := httptest.NewRequest(http.MethodGet, "/Users/0001", nil)
+			req.Header.Set("If-None-Match", test.ifNoneMatch)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+			assertEqual(t, "v1", rr.Header().Get("Etag"))
+			if rr.Code == http.StatusNotModified {
+				assertEqual(t, 0, rr.Body.Len())
+			}
+		})
+	}
+}
+
+func TestServerResourcePatchHandlerIfMatch(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportETag = true
+	req := httptest.NewRequest(http.MethodPatch, "/Users/0001", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "path": "userName", "value": "test"}]
+	}`))
+	req.Header.Set("If-Match", `W/"v0"`)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusPreconditionFailed, rr.Code)
+}
+
+func TestServerResourcePutHandlerIfMatch(t *testing.T) {
+	tests := []struct {
+		name               string
+		target             string
+		ifMatch            string
+		expectedStatusCode int
+	}{
+		{name: "matching version", target: "/Users/0001", ifMatch: `W/"v1"`, expectedStatusCode: http.StatusOK},
+		{name: "changed version", target: "/Users/0001", ifMatch: `W/"v0"`, expectedStatusCode: http.StatusPreconditionFailed},
+		{name: "unknown resource", target: "/Users/9999", ifMatch: "*", expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer()
+			s.Config.SupportETag = true
+			req := httptest.NewRequest(http.MethodPut, test.target, strings.NewReader(`{"userName": "other"}`))
+			req.Header.Set("If-Match", test.ifMatch)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+		})
+	}
+}
+
+func TestServerResourceDeleteHandlerExpectedVersion(t *testing.T) {
+	h := newTestServer().ResourceTypes[0].Handler.(testResourceHandler)
+
+	// The handler is responsible for checking the expected version when applying the modification, since the resource
+	// can be modified between the check of the server and the call of the handler.
+	req := httptest.NewRequest(http.MethodDelete, "/Users/0001", nil)
+	req = req.WithContext(withExpectedVersion(req.Context(), "v0"))
+	err := h.Delete(req, "0001")
+	assertNotNil(t, err, "error")
+}
+
+func TestServerServiceProviderConfigHandlerETag(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportETag = true
+	req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var config map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &config))
+	etag, ok := config["etag"].(map[string]interface{})
+	assertTypeOk(t, ok, "object")
+	assertEqual(t, true, etag["supported"])
+}
@@ -2,13 +2,14 @@ package filter
 
 import (
 	"fmt"
+	"github.com/elimity-com/scim/internal/numeric"
 	"github.com/scim2/filter-parser/v2"
 	"strings"
 )
 
 func cmpInt(ref int, cmp func(v, ref int) error) func(interface{}) error {
 	return func(i interface{}) error {
-		v, ok := i.(int)
+		v, ok := numeric.Int(i)
 		if !ok {
 			panic(fmt.Sprintf("given value is not an integer: %v", i))
 		}
@@ -18,10 +19,11 @@ func cmpInt(ref int, cmp func(v, ref int) error) func(interface{}) error {
 
 func cmpIntStr(ref int, cmp func(v, ref string) error) (func(interface{}) error, error) {
 	return func(i interface{}) error {
-		if _, ok := i.(int); !ok {
+		v, ok := numeric.Int(i)
+		if !ok {
 			panic(fmt.Sprintf("given value is not an integer: %v", i))
 		}
-		return cmp(fmt.Sprintf("%d", i), fmt.Sprintf("%d", ref))
+		return cmp(fmt.Sprintf("%d", v), fmt.Sprintf("%d", ref))
 	}, nil
 }
 
@@ -1,8 +1,10 @@
 module github.com/elimity-com/scim
 
-go 1.16
+go 1.18
 
 require (
 	github.com/di-wu/xsd-datetime v1.0.0
 	github.com/scim2/filter-parser/v2 v2.2.0
 )
+
+require github.com/di-wu/parser v0.2.2 // indirect
@@ -2,30 +2,58 @@ package scim
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+	"net/url"
 
 	"github.com/elimity-com/scim/errors"
 	f "github.com/elimity-com/scim/filter"
 	"github.com/elimity-com/scim/schema"
 )
 
-func errorHandler(w http.ResponseWriter, _ *http.Request, scimErr *errors.ScimError) {
+func errorHandler(w http.ResponseWriter, r *http.Request, scimErr *errors.ScimError) {
+	if record := requestLogFrom(r); record != nil {
+		record.scimType = string(scimErr.ScimType)
+	}
+
 	raw, err := json.Marshal(scimErr)
 	if err != nil {
-		log.Fatalf("failed marshaling scim error: %v", err)
+		logError(r, "failed marshaling scim error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
 	w.WriteHeader(scimErr.Status)
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
 // resourceDeleteHandler receives an HTTP DELETE request to the resource endpoint, e.g., "/Users/{id}" or "/Groups/{id}",
 // where "{id}" is a resource identifier to delete a known resource.
 func (s Server) resourceDeleteHandler(w http.ResponseWriter, r *http.Request, id string, resourceType ResourceType) {
+	input := OperationInput{
+		ResourceType: resourceType,
+		Operation:    OperationDelete,
+		ID:           id,
+	}
+	if scimErr := s.authorizeOperation(r, input); scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	r, scimErr := s.checkPreconditions(r, id, resourceType)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	input, scimErr = s.beforeOperation(r, input)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
 	deleteErr := resourceType.Handler.Delete(r, id)
 	if deleteErr != nil {
 		scimErr := errors.CheckScimError(deleteErr, http.MethodDelete)
@@ -33,12 +61,30 @@ func (s Server) resourceDeleteHandler(w http.ResponseWriter, r *http.Request, id
 		return
 	}
 
+	if _, scimErr := s.afterOperation(r, input, Resource{ID: id}); scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // resourceGetHandler receives an HTTP GET request to the resource endpoint, e.g., "/Users/{id}" or "/Groups/{id}",
 // where "{id}" is a resource identifier to retrieve a known resource.
 func (s Server) resourceGetHandler(w http.ResponseWriter, r *http.Request, id string, resourceType ResourceType) {
+	access, scimErr := s.authorize(r, resourceType, OperationRead)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	projection, scimErr := newAttributeProjection(r.URL.Query())
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+	projection.access = access
+
 	resource, getErr := resourceType.Handler.Get(r, id)
 	if getErr != nil {
 		scimErr := errors.CheckScimError(getErr, http.MethodGet)
@@ -46,10 +92,16 @@ func (s Server) resourceGetHandler(w http.ResponseWriter, r *http.Request, id st
 		return
 	}
 
-	raw, err := json.Marshal(resource.response(resourceType))
+	if s.notModified(r, resource.Meta.Version) {
+		w.Header().Set("Etag", resource.Meta.Version)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	raw, err := json.Marshal(projection.apply(resourceType, resource.response(resourceType, s.baseURL(r))))
 	if err != nil {
+		logError(r, "failed marshaling resource", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling resource: %v", err)
 		return
 	}
 
@@ -59,35 +111,80 @@ func (s Server) resourceGetHandler(w http.ResponseWriter, r *http.Request, id st
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
 // resourcePatchHandler receives an HTTP PATCH to the resource endpoint, e.g., "/Users/{id}" or "/Groups/{id}", where
 // "{id}" is a resource identifier to replace a resource's attributes.
 func (s Server) resourcePatchHandler(w http.ResponseWriter, r *http.Request, id string, resourceType ResourceType) {
+	projection, scimErr := newAttributeProjection(r.URL.Query())
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	_, end := s.startPhase(r, PhaseObservation{Phase: PhaseValidation, ResourceType: resourceType.Name})
 	patch, scimErr := resourceType.validatePatch(r)
+	end(scimError(scimErr))
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	input := OperationInput{
+		ResourceType:    resourceType,
+		Operation:       OperationPatch,
+		ID:              id,
+		PatchOperations: patch,
+	}
+	if scimErr := s.authorizeOperation(r, input); scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	r, scimErr = s.checkPreconditions(r, id, resourceType)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	input, scimErr = s.beforeOperation(r, input)
 	if scimErr != nil {
 		errorHandler(w, r, scimErr)
 		return
 	}
+	projection.requested = patchedAttributePaths(resourceType, input.PatchOperations)
+	projection.access = s.readAccess(r, resourceType)
 
-	resource, patchErr := resourceType.Handler.Patch(r, id, patch)
+	var resource Resource
+	var patchErr error
+	if resourceType.PatchByReplace {
+		resource, patchErr = resourceType.patchByReplace(r, id, input.PatchOperations)
+	} else {
+		resource, patchErr = resourceType.Handler.Patch(r, id, input.PatchOperations)
+	}
 	if patchErr != nil {
 		scimErr := errors.CheckScimError(patchErr, http.MethodPatch)
 		errorHandler(w, r, &scimErr)
 		return
 	}
 
+	resource, scimErr = s.afterOperation(r, input, resource)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
 	if len(resource.Attributes) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	raw, err := json.Marshal(resource.response(resourceType))
+	raw, err := json.Marshal(projection.apply(resourceType, resource.response(resourceType, s.baseURL(r))))
 	if err != nil {
+		logError(r, "failed marshaling resource", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling resource: %v", err)
 		return
 	}
 
@@ -99,32 +196,64 @@ func (s Server) resourcePatchHandler(w http.ResponseWriter, r *http.Request, id
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
 // resourcePostHandler receives an HTTP POST request to the resource endpoint, such as "/Users" or "/Groups", as
 // defined by the associated resource type endpoint discovery to create new resources.
 func (s Server) resourcePostHandler(w http.ResponseWriter, r *http.Request, resourceType ResourceType) {
+	projection, scimErr := newAttributeProjection(r.URL.Query())
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
 	data, _ := readBody(r)
 
+	_, end := s.startPhase(r, PhaseObservation{Phase: PhaseValidation, ResourceType: resourceType.Name})
 	attributes, scimErr := resourceType.validate(data)
+	end(scimError(scimErr))
 	if scimErr != nil {
 		errorHandler(w, r, scimErr)
 		return
 	}
 
-	resource, postErr := resourceType.Handler.Create(r, attributes)
+	input := OperationInput{
+		ResourceType: resourceType,
+		Operation:    OperationCreate,
+		Attributes:   attributes,
+	}
+	if scimErr := s.authorizeOperation(r, input); scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	input, scimErr = s.beforeOperation(r, input)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+	projection.requested = requestedAttributePaths(resourceType, input.Attributes)
+	projection.access = s.readAccess(r, resourceType)
+
+	resource, postErr := resourceType.Handler.Create(r, input.Attributes)
 	if postErr != nil {
 		scimErr := errors.CheckScimError(postErr, http.MethodPost)
 		errorHandler(w, r, &scimErr)
 		return
 	}
 
-	raw, err := json.Marshal(resource.response(resourceType))
+	resource, scimErr = s.afterOperation(r, input, resource)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	raw, err := json.Marshal(projection.apply(resourceType, resource.response(resourceType, s.baseURL(r))))
 	if err != nil {
+		logError(r, "failed marshaling resource", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling resource: %v", err)
 		return
 	}
 
@@ -132,36 +261,76 @@ func (s Server) resourcePostHandler(w http.ResponseWriter, r *http.Request, reso
 		w.Header().Set("Etag", resource.Meta.Version)
 	}
 
+	w.Header().Set("Location", resourceType.location(s.baseURL(r), resource.ID))
 	w.WriteHeader(http.StatusCreated)
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
 // resourcePutHandler receives an HTTP PUT to the resource endpoint, e.g., "/Users/{id}" or "/Groups/{id}", where
 // "{id}" is a resource identifier to replace a resource's attributes.
 func (s Server) resourcePutHandler(w http.ResponseWriter, r *http.Request, id string, resourceType ResourceType) {
+	projection, scimErr := newAttributeProjection(r.URL.Query())
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
 	data, _ := readBody(r)
 
+	_, end := s.startPhase(r, PhaseObservation{Phase: PhaseValidation, ResourceType: resourceType.Name})
 	attributes, scimErr := resourceType.validate(data)
+	end(scimError(scimErr))
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	input := OperationInput{
+		ResourceType: resourceType,
+		Operation:    OperationReplace,
+		ID:           id,
+		Attributes:   attributes,
+	}
+	if scimErr := s.authorizeOperation(r, input); scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	r, scimErr = s.checkPreconditions(r, id, resourceType)
 	if scimErr != nil {
 		errorHandler(w, r, scimErr)
 		return
 	}
 
-	resource, putError := resourceType.Handler.Replace(r, id, attributes)
+	input, scimErr = s.beforeOperation(r, input)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+	projection.requested = requestedAttributePaths(resourceType, input.Attributes)
+	projection.access = s.readAccess(r, resourceType)
+
+	resource, putError := resourceType.Handler.Replace(r, id, input.Attributes)
 	if putError != nil {
 		scimErr := errors.CheckScimError(putError, http.MethodPut)
 		errorHandler(w, r, &scimErr)
 		return
 	}
 
-	raw, err := json.Marshal(resource.response(resourceType))
+	resource, scimErr = s.afterOperation(r, input, resource)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	raw, err := json.Marshal(projection.apply(resourceType, resource.response(resourceType, s.baseURL(r))))
 	if err != nil {
+		logError(r, "failed marshaling resource", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling resource: %v", err)
 		return
 	}
 
@@ -171,37 +340,30 @@ func (s Server) resourcePutHandler(w http.ResponseWriter, r *http.Request, id st
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
 // resourceTypeHandler receives an HTTP GET to retrieve individual resource types which can be returned by appending the
 // resource types name to the /ResourceTypes endpoint. For example: "/ResourceTypes/User".
 func (s Server) resourceTypeHandler(w http.ResponseWriter, r *http.Request, name string) {
-	var resourceType ResourceType
-	for _, r := range s.ResourceTypes {
-		if r.Name == name {
-			resourceType = r
-			break
-		}
-	}
-
-	if resourceType.Name != name {
+	resourceType, ok := s.getResourceType(name)
+	if !ok {
 		scimErr := errors.ScimErrorResourceNotFound(name)
 		errorHandler(w, r, &scimErr)
 		return
 	}
 
-	raw, err := json.Marshal(resourceType.getRaw())
+	raw, err := json.Marshal(resourceType.getRaw(s.baseURL(r)))
 	if err != nil {
+		logError(r, "failed marshaling resource type", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling resource type: %v", err)
 		return
 	}
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
@@ -218,7 +380,7 @@ func (s Server) resourceTypesHandler(w http.ResponseWriter, r *http.Request) {
 	start, end := clamp(params.StartIndex-1, params.Count, len(s.ResourceTypes))
 	var resources []interface{}
 	for _, v := range s.ResourceTypes[start:end] {
-		resources = append(resources, v.getRaw())
+		resources = append(resources, v.getRaw(s.baseURL(r)))
 	}
 
 	raw, err := json.Marshal(listResponse{
@@ -228,26 +390,38 @@ func (s Server) resourceTypesHandler(w http.ResponseWriter, r *http.Request) {
 		Resources:    resources,
 	})
 	if err != nil {
+		logError(r, "failed marshaling list response", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling list response: %v", err)
 		return
 	}
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
 // resourcesGetHandler receives an HTTP GET request to the resource endpoint, e.g., "/Users" or "/Groups", to retrieve
 // all known resources.
 func (s Server) resourcesGetHandler(w http.ResponseWriter, r *http.Request, resourceType ResourceType) {
-	params, paramsErr := s.parseRequestParams(r, resourceType.Schema, resourceType.getSchemaExtensions()...)
+	s.resourcesListHandler(w, r, r.URL.Query(), resourceType)
+}
+
+// resourcesListHandler retrieves all known resources of the given resource type based on the given parameters.
+func (s Server) resourcesListHandler(w http.ResponseWriter, r *http.Request, values url.Values, resourceType ResourceType) {
+	_, end := s.startPhase(r, PhaseObservation{Phase: PhaseFilterParsing, ResourceType: resourceType.Name})
+	params, paramsErr := s.parseListRequestParams(values, resourceType.Schema, resourceType.getSchemaExtensions()...)
+	end(scimError(paramsErr))
 	if paramsErr != nil {
 		errorHandler(w, r, paramsErr)
 		return
 	}
 
+	if scimErr := s.authorizeList(r, resourceType, params); scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
 	page, getError := resourceType.Handler.GetAll(r, params)
 	if getError != nil {
 		scimErr := errors.CheckScimError(getError, http.MethodGet)
@@ -255,24 +429,143 @@ func (s Server) resourcesGetHandler(w http.ResponseWriter, r *http.Request, reso
 		return
 	}
 
+	if resourceType.SortPages {
+		SortResources(resourceType, params, page.Resources)
+	}
+
+	projection := params.projection()
+	projection.access = s.readAccess(r, resourceType)
 	raw, err := json.Marshal(listResponse{
 		TotalResults: page.TotalResults,
-		Resources:    page.resources(resourceType),
+		Resources:    page.resources(resourceType, projection, s.baseURL(r)),
 		StartIndex:   params.StartIndex,
 		ItemsPerPage: params.Count,
 	})
 	if err != nil {
+		logError(r, "failed marshalling list response", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshalling list response: %v", err)
 		return
 	}
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
+// resourcesSearchHandler receives an HTTP POST request to the search endpoint of a resource type, e.g.,
+// "/Users/.search", to retrieve all known resources that match the query in the request body.
+func (s Server) resourcesSearchHandler(w http.ResponseWriter, r *http.Request, resourceType ResourceType) {
+	values, scimErr := parseSearchRequest(r)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	s.resourcesListHandler(w, r, values, resourceType)
+}
+
+// rootGetHandler receives an HTTP GET request to the root endpoint, e.g., "/" or "/v2/", to retrieve all known
+// resources of all resource types that match the query in the url.
+func (s Server) rootGetHandler(w http.ResponseWriter, r *http.Request) {
+	s.rootListHandler(w, r, r.URL.Query())
+}
+
+// rootListHandler retrieves the resources of all resource types based on the given parameters. The resources of all
+// resource types are merged into a single list response, in the order in which the resource types are defined. Sorting
+// is not supported, since the resources of the different resource types are paged separately.
+func (s Server) rootListHandler(w http.ResponseWriter, r *http.Request, values url.Values) {
+	_, end := s.startPhase(r, PhaseObservation{Phase: PhaseFilterParsing})
+	params, paramsErr := s.parseListRequestParams(values, s.getUnionSchema(), s.getSchemas()...)
+	end(scimError(paramsErr))
+	if paramsErr != nil {
+		errorHandler(w, r, paramsErr)
+		return
+	}
+	if params.SortBy != nil {
+		errorHandler(w, r, &errors.ScimError{
+			ScimType: errors.ScimTypeInvalidValue,
+			Detail:   "The sortBy parameter is not supported for queries across all resource types.",
+			Status:   http.StatusBadRequest,
+		})
+		return
+	}
+
+	var (
+		totalResults int
+		resources    = make([]interface{}, 0)
+	)
+	for _, resourceType := range s.ResourceTypes {
+		// Resource types that the client is not permitted to read are skipped.
+		if _, scimErr := s.authorize(r, resourceType, OperationRead); scimErr != nil {
+			continue
+		}
+		if scimErr := s.authorizeList(r, resourceType, params); scimErr != nil {
+			errorHandler(w, r, scimErr)
+			return
+		}
+
+		// The start index is relative to the resources of the previous resource types.
+		startIndex := params.StartIndex - totalResults
+		if startIndex < defaultStartIndex {
+			startIndex = defaultStartIndex
+		}
+
+		// The count is shared by all resource types.
+		count := params.Count - len(resources)
+		if count < 0 {
+			count = 0
+		}
+
+		page, getError := resourceType.Handler.GetAll(r, ListRequestParams{
+			Attributes:         params.Attributes,
+			Count:              count,
+			ExcludedAttributes: params.ExcludedAttributes,
+			Filter:             params.Filter,
+			StartIndex:         startIndex,
+		})
+		if getError != nil {
+			scimErr := errors.CheckScimError(getError, http.MethodGet)
+			errorHandler(w, r, &scimErr)
+			return
+		}
+
+		projection := params.projection()
+		projection.access = s.readAccess(r, resourceType)
+		totalResults += page.TotalResults
+		resources = append(resources, page.resources(resourceType, projection, s.baseURL(r))...)
+	}
+
+	raw, err := json.Marshal(listResponse{
+		TotalResults: totalResults,
+		Resources:    resources,
+		StartIndex:   params.StartIndex,
+		ItemsPerPage: params.Count,
+	})
+	if err != nil {
+		logError(r, "failed marshalling list response", err)
+		errorHandler(w, r, &errors.ScimErrorInternal)
+		return
+	}
+
+	_, err = w.Write(raw)
+	if err != nil {
+		logError(r, "failed writing response", err)
+	}
+}
+
+// rootSearchHandler receives an HTTP POST request to the search endpoint at the root, "/.search", to retrieve all
+// known resources of all resource types that match the query in the request body.
+func (s Server) rootSearchHandler(w http.ResponseWriter, r *http.Request) {
+	values, scimErr := parseSearchRequest(r)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
+	s.rootListHandler(w, r, values)
+}
+
 // schemaHandler receives an HTTP GET to retrieve individual schema definitions which can be returned by appending the
 // schema URI to the /Schemas endpoint. For example: "/Schemas/urn:ietf:params:scim:schemas:core:2.0:User".
 func (s Server) schemaHandler(w http.ResponseWriter, r *http.Request, id string) {
@@ -283,16 +576,16 @@ func (s Server) schemaHandler(w http.ResponseWriter, r *http.Request, id string)
 		return
 	}
 
-	raw, err := json.Marshal(getSchema)
+	raw, err := json.Marshal(s.schemaResource(r, getSchema))
 	if err != nil {
+		logError(r, "failed marshaling schema", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling schema: %v", err)
 		return
 	}
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
@@ -317,7 +610,7 @@ func (s Server) schemasHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	for _, v := range s.getSchemas()[start:end] {
-		resource := v.ToMap()
+		resource := s.schemaResource(r, v)
 		if params.Filter != nil {
 			if err := validator.PassesFilter(resource); err != nil {
 				continue
@@ -333,29 +626,29 @@ func (s Server) schemasHandler(w http.ResponseWriter, r *http.Request) {
 		Resources:    resources,
 	})
 	if err != nil {
+		logError(r, "failed marshaling list response", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling list response: %v", err)
 		return
 	}
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
 
 // serviceProviderConfigHandler receives an HTTP GET to this endpoint will return a JSON structure that describes the
 // SCIM specification features available on a service provider.
 func (s Server) serviceProviderConfigHandler(w http.ResponseWriter, r *http.Request) {
-	raw, err := json.Marshal(s.Config.getRaw())
+	raw, err := json.Marshal(s.Config.getRaw(s.baseURL(r)))
 	if err != nil {
+		logError(r, "failed marshaling service provider config", err)
 		errorHandler(w, r, &errors.ScimErrorInternal)
-		log.Fatalf("failed marshaling service provider config: %v", err)
 		return
 	}
 
 	_, err = w.Write(raw)
 	if err != nil {
-		log.Printf("failed writing response: %v", err)
+		logError(r, "failed writing response", err)
 	}
 }
@@ -673,6 +673,60 @@ func TestServerResourcesGetHandler(t *testing.T) {
 	assertEqual(t, 20, len(response.Resources))
 }
 
+func TestServerRootGetHandler(t *testing.T) {
+	params := url.Values{
+		"filter":     []string{"userName sw \"test\""},
+		"startIndex": []string{"35"},
+		"count":      []string{"10"},
+	}
+	for _, target := range []string{"/?", "/v2?", "/v2/?"} {
+		t.Run(target, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, target+params.Encode(), nil)
+			rr := httptest.NewRecorder()
+			newTestServer().ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+			var response listResponse
+			assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+			// Three resource types with twenty resources each.
+			assertEqual(t, 60, response.TotalResults)
+			assertEqual(t, 35, response.StartIndex)
+			assertLen(t, response.Resources, 10)
+
+			// The page contains the last six enterprise users and the first four groups.
+			resourceTypes := make(map[string]int)
+			for _, resource := range response.Resources {
+				meta := resource.(map[string]interface{})["meta"].(map[string]interface{})
+				resourceTypes[meta["resourceType"].(string)]++
+			}
+			assertEqual(t, 6, resourceTypes["EnterpriseUser"])
+			assertEqual(t, 4, resourceTypes["Group"])
+		})
+	}
+}
+
+func TestServerRootGetHandlerInvalidFilter(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v2/?filter="+url.QueryEscape(`invalid eq "test"`), nil)
+	rr := httptest.NewRecorder()
+	newTestServer().ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestServerRootGetHandlerSortBy(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v2/?sortBy=userName&count=5", nil)
+	rr := httptest.NewRecorder()
+	newTestServer().ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+
+	var scimErr errors.ScimError
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &scimErr))
+	assertEqual(t, errors.ScimTypeInvalidValue, scimErr.ScimType)
+}
+
 func TestServerResourcesGetHandlerMaxCount(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/Users?count=20000", nil)
 	rr := httptest.NewRecorder()
@@ -826,6 +880,95 @@ func TestServerServiceProviderConfigHandler(t *testing.T) {
 	}
 }
 
+func TestServerMaxPayloadSize(t *testing.T) {
+	s := newTestServer()
+	s.Config.MaxPayloadSize = 64
+
+	small := `{"userName": "small"}`
+	large := fmt.Sprintf(`{"userName": "%s"}`, strings.Repeat("x", 64))
+	patch := fmt.Sprintf(`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "userName", "value": "%s"}]}`, strings.Repeat("x", 64))
+	tests := []struct {
+		name               string
+		method             string
+		target             string
+		body               string
+		unknownLength      bool
+		expectedStatusCode int
+	}{
+		{name: "create", method: http.MethodPost, target: "/Users", body: small, expectedStatusCode: http.StatusCreated},
+		{name: "create too large", method: http.MethodPost, target: "/Users", body: large, expectedStatusCode: http.StatusRequestEntityTooLarge},
+		{name: "create too large without content length", method: http.MethodPost, target: "/Users", body: large, unknownLength: true, expectedStatusCode: http.StatusRequestEntityTooLarge},
+		{name: "replace too large", method: http.MethodPut, target: "/Users/0001", body: large, expectedStatusCode: http.StatusRequestEntityTooLarge},
+		{name: "patch too large", method: http.MethodPatch, target: "/Users/0001", body: patch, expectedStatusCode: http.StatusRequestEntityTooLarge},
+		{name: "search too large", method: http.MethodPost, target: "/Users/.search", body: large, expectedStatusCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			if test.unknownLength {
+				req.ContentLength = -1
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+		})
+	}
+
+	// The limit is advertised in the service provider config.
+	req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	var config struct {
+		Bulk struct {
+			MaxPayloadSize int
+		}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &config))
+	assertEqual(t, 64, config.Bulk.MaxPayloadSize)
+}
+
+func TestServerMaxPayloadSizeSeparateBulk(t *testing.T) {
+	s := newTestServer()
+	s.Config.MaxPayloadSize = 64
+	s.Config.BulkMaxPayloadSize = 1024
+
+	large := fmt.Sprintf(`{"userName": "%s"}`, strings.Repeat("x", 64))
+	patch := fmt.Sprintf(`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "userName", "value": "%s"}]}`, strings.Repeat("x", 64))
+	for _, test := range []struct {
+		method string
+		body   string
+	}{
+		{method: http.MethodPut, body: large},
+		{method: http.MethodPatch, body: patch},
+	} {
+		req := httptest.NewRequest(test.method, "/Users/0001", strings.NewReader(test.body))
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+
+		assertEqualStatusCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+		var scimErr errors.ScimError
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &scimErr))
+		expected := errors.ScimErrorRequestEntityTooLarge("The size of the request exceeds the maxPayloadSize (64).")
+		assertEqualSCIMErrors(t, &expected, &scimErr)
+	}
+
+	// Only the bulk limit is advertised in the service provider config.
+	req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	var config struct {
+		Bulk struct {
+			MaxPayloadSize int
+		}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &config))
+	assertEqual(t, 1024, config.Bulk.MaxPayloadSize)
+}
+
 func getUserExtensionSchema() schema.Schema {
 	return schema.Schema{
 		ID:          "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
@@ -934,7 +1077,9 @@ func newTestServer() Server {
 	userSchema := getUserSchema()
 	userSchemaExtension := getUserExtensionSchema()
 	return Server{
-		Config: ServiceProviderConfig{},
+		Config: ServiceProviderConfig{
+			SupportSort: true,
+		},
 		ResourceTypes: []ResourceType{
 			{
 				ID:          optional.NewString("User"),
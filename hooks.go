@@ -0,0 +1,76 @@
+package scim
+
+import (
+	"net/http"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// Operation is the kind of modification that is requested on a resource.
+type Operation string
+
+const (
+	// OperationCreate creates a new resource, i.e. a POST request to the endpoint of a resource type.
+	OperationCreate Operation = "create"
+	// OperationReplace replaces the attributes of a resource, i.e. a PUT request.
+	OperationReplace Operation = "replace"
+	// OperationPatch updates the attributes of a resource with PATCH operations, i.e. a PATCH request.
+	OperationPatch Operation = "patch"
+	// OperationDelete deletes a resource, i.e. a DELETE request.
+	OperationDelete Operation = "delete"
+	// OperationRead retrieves resources, i.e. a GET request or a search. It is only used by authorization policies,
+	// hooks are not called for read operations.
+	OperationRead Operation = "read"
+)
+
+// OperationInput is the (validated) input of an operation on a resource, as it is passed to the resource handler.
+type OperationInput struct {
+	// ResourceType is the resource type of the resource.
+	ResourceType ResourceType
+	// Operation is the kind of operation.
+	Operation Operation
+	// ID is the identifier of the resource. It is empty for create operations.
+	ID string
+	// Attributes are the validated attributes of a create or replace operation.
+	Attributes ResourceAttributes
+	// PatchOperations are the validated operations of a patch operation.
+	PatchOperations []PatchOperation
+}
+
+// BeforeHook is called before an operation is passed to the resource handler. It returns the input that is passed to
+// the resource handler, which allows the hook to rewrite the attributes or PATCH operations. Changes to the other
+// fields of the input are ignored. The operation is vetoed if an error is returned, in which case the error (e.g., a
+// SCIM error) is returned to the client.
+type BeforeHook func(r *http.Request, input OperationInput) (OperationInput, error)
+
+// AfterHook is called after an operation is successfully handled by the resource handler, with the resource that is
+// returned by the handler. The resource of a delete operation only contains its identifier. It returns the resource
+// that is returned to the client. Note that the operation already took effect if an error is returned.
+type AfterHook func(r *http.Request, input OperationInput, resource Resource) (Resource, error)
+
+// beforeOperation calls all the before hooks in order, each with the input returned by the previous hook. The client
+// is expected to be authorized to perform the operation already, see authorizeOperation.
+func (s Server) beforeOperation(r *http.Request, input OperationInput) (OperationInput, *errors.ScimError) {
+	for _, hook := range s.BeforeHooks {
+		hooked, err := hook(r, input)
+		if err != nil {
+			scimErr := errors.CheckScimError(err, r.Method)
+			return input, &scimErr
+		}
+		input.Attributes = hooked.Attributes
+		input.PatchOperations = hooked.PatchOperations
+	}
+	return input, nil
+}
+
+// afterOperation calls all the after hooks in order, each with the resource returned by the previous hook.
+func (s Server) afterOperation(r *http.Request, input OperationInput, resource Resource) (Resource, *errors.ScimError) {
+	for _, hook := range s.AfterHooks {
+		var err error
+		if resource, err = hook(r, input, resource); err != nil {
+			scimErr := errors.CheckScimError(err, r.Method)
+			return resource, &scimErr
+		}
+	}
+	return resource, nil
+}
@@ -0,0 +1,144 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+func TestServerBeforeHooks(t *testing.T) {
+	var inputs []OperationInput
+	s := newTestServer()
+	s.BeforeHooks = []BeforeHook{
+		func(r *http.Request, input OperationInput) (OperationInput, error) {
+			inputs = append(inputs, input)
+			if input.Operation == OperationDelete && input.ResourceType.Name == "Group" {
+				return input, errors.ScimError{
+					Detail: "Groups can not be deleted.",
+					Status: http.StatusForbidden,
+				}
+			}
+			if input.Attributes != nil {
+				input.Attributes["displayName"] = "hooked"
+			}
+			return input, nil
+		},
+	}
+
+	tests := []struct {
+		name               string
+		method             string
+		target             string
+		body               string
+		expectedStatusCode int
+		expectedInput      OperationInput
+	}{
+		{
+			name:               "create",
+			method:             http.MethodPost,
+			target:             "/Users",
+			body:               `{"userName": "test"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedInput:      OperationInput{Operation: OperationCreate},
+		},
+		{
+			name:               "replace",
+			method:             http.MethodPut,
+			target:             "/Users/0001",
+			body:               `{"userName": "test"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedInput:      OperationInput{Operation: OperationReplace, ID: "0001"},
+		},
+		{
+			name:               "veto",
+			method:             http.MethodDelete,
+			target:             "/Groups/0001",
+			expectedStatusCode: http.StatusForbidden,
+			expectedInput:      OperationInput{Operation: OperationDelete, ID: "0001"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inputs = nil
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+			assertLen(t, inputs, 1)
+			assertEqual(t, test.expectedInput.Operation, inputs[0].Operation)
+			assertEqual(t, test.expectedInput.ID, inputs[0].ID)
+			if rr.Code >= http.StatusBadRequest {
+				return
+			}
+
+			// The rewritten attributes are passed to the handler.
+			var resource map[string]interface{}
+			assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+			assertEqual(t, "hooked", resource["displayName"])
+		})
+	}
+
+	// The group still exists after the vetoed delete.
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/Groups/0001", nil))
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+}
+
+func TestServerBeforeHooksPatch(t *testing.T) {
+	var operations []PatchOperation
+	s := newTestServer()
+	s.BeforeHooks = []BeforeHook{
+		func(r *http.Request, input OperationInput) (OperationInput, error) {
+			operations = input.PatchOperations
+			return input, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/Users/0001", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "path": "displayName", "value": "Babs"}]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	assertLen(t, operations, 1)
+	assertEqual(t, PatchOperationReplace, operations[0].Op)
+	assertEqual(t, "displayName", operations[0].Path.String())
+}
+
+func TestServerAfterHooks(t *testing.T) {
+	lastModified := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var deleted []string
+	s := newTestServer()
+	s.AfterHooks = []AfterHook{
+		func(r *http.Request, input OperationInput, resource Resource) (Resource, error) {
+			if input.Operation == OperationDelete {
+				deleted = append(deleted, resource.ID)
+			}
+			resource.Meta.LastModified = &lastModified
+			return resource, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/Users/0001", strings.NewReader(`{"userName": "test"}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var resource map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+	assertEqual(t, "2021-01-01T00:00:00Z", resource["meta"].(map[string]interface{})["lastModified"])
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/Users/0002", nil))
+	assertEqualStatusCode(t, http.StatusNoContent, rr.Code)
+	assertEqualStrings(t, []string{"0002"}, deleted)
+}
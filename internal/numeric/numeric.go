@@ -0,0 +1,45 @@
+// Package numeric converts the different representations of numeric attribute values. Validated integers are
+// represented as an int64, validated decimals as a float64 and values that are decoded with json.Decoder.UseNumber as a
+// json.Number.
+package numeric
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Float converts the given numeric value to a float64. Any integer or floating-point type is accepted, as well as a
+// json.Number.
+func Float(value interface{}) (float64, bool) {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// Int converts the given integer value to an int. Only the types that pass the validation of integer attributes are
+// accepted, i.e. the signed integer types and a json.Number without fraction or exponent.
+func Int(value interface{}) (int, bool) {
+	if n, ok := value.(json.Number); ok {
+		i, err := n.Int64()
+		return int(i), err == nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int()), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,38 @@
+package numeric
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFloat(t *testing.T) {
+	for _, value := range []interface{}{
+		1, int8(1), int16(1), int32(1), int64(1), uint(1), uint8(1), uint16(1), uint32(1), uint64(1),
+		float32(1), float64(1), json.Number("1"), json.Number("1.0"),
+	} {
+		if f, ok := Float(value); !ok || f != 1 {
+			t.Errorf("%T: expected 1, got %v (%t)", value, f, ok)
+		}
+	}
+	for _, value := range []interface{}{nil, "1", true, json.Number("one")} {
+		if _, ok := Float(value); ok {
+			t.Errorf("%T: expected no number", value)
+		}
+	}
+}
+
+func TestInt(t *testing.T) {
+	for _, value := range []interface{}{
+		1, int8(1), int16(1), int32(1), int64(1), json.Number("1"),
+	} {
+		if i, ok := Int(value); !ok || i != 1 {
+			t.Errorf("%T: expected 1, got %v (%t)", value, i, ok)
+		}
+	}
+	// Only values that pass the validation of integer attributes are integers.
+	for _, value := range []interface{}{nil, "1", uint(1), float64(1), json.Number("1.0")} {
+		if _, ok := Int(value); ok {
+			t.Errorf("%T: expected no integer", value)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package scim
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// JWTVerifier verifies bearer tokens that are JSON Web Tokens (JWT), signed with one of the keys of a JSON Web Key Set
+// (JWKS). The supported signing algorithms are RS256, ES256 and HS256. The keys are configured locally and can be
+// reloaded at any time, e.g., to rotate them. Use its Verify method as the verifier of a BearerTokenAuthenticator.
+//
+// Besides the signature, the "exp" and "nbf" claims are always validated. Tokens without "exp" claim are rejected,
+// unless AllowMissingExpiration is set. The "iss", "aud" and "scope" claims are validated if the corresponding fields
+// are set. The subject ("sub") of the token is the identifier of the
+// authenticated principal, and all claims of the token are available as the claims of the principal.
+// More info: https://datatracker.ietf.org/doc/html/rfc7519
+type JWTVerifier struct {
+	// Issuer is the expected issuer of the tokens, i.e. the "iss" claim. It is not validated if empty.
+	Issuer string
+	// Audience is the expected audience of the tokens, i.e. one of the values of the "aud" claim. It is not validated
+	// if empty.
+	Audience string
+	// Scopes are the scopes that need to be granted by the tokens, i.e. the space-separated values of the "scope"
+	// claim. Tokens without these scopes result in a 403 Forbidden response.
+	Scopes []string
+	// Leeway is the allowed clock skew when validating the "exp" and "nbf" claims.
+	Leeway time.Duration
+	// AllowMissingExpiration indicates whether tokens without "exp" claim are accepted. Such tokens never expire, so
+	// they can only be revoked by rotating the signing keys.
+	AllowMissingExpiration bool
+
+	keys *jwkSet
+	now  func() time.Time
+}
+
+// NewJWTVerifier returns a JWT verifier that verifies the signatures of the tokens with the keys of the given JWKS
+// document.
+func NewJWTVerifier(jwks []byte) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		keys: &jwkSet{},
+	}
+	if err := v.LoadKeys(jwks); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewJWTVerifierFromFile returns a JWT verifier that verifies the signatures of the tokens with the keys of the JWKS
+// document in the file with the given name.
+func NewJWTVerifierFromFile(filename string) (*JWTVerifier, error) {
+	jwks, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewJWTVerifier(jwks)
+}
+
+// LoadKeys replaces the keys of the verifier with the keys of the given JWKS document. The current keys are kept if
+// the document is invalid.
+func (v *JWTVerifier) LoadKeys(jwks []byte) error {
+	keys, err := parseJWKS(jwks)
+	if err != nil {
+		return err
+	}
+	if v.keys == nil {
+		v.keys = &jwkSet{}
+	}
+	v.keys.mu.Lock()
+	defer v.keys.mu.Unlock()
+	v.keys.keys = keys
+	return nil
+}
+
+// LoadKeysFromFile replaces the keys of the verifier with the keys of the JWKS document in the file with the given
+// name. The current keys are kept if the document is invalid.
+func (v *JWTVerifier) LoadKeysFromFile(filename string) error {
+	jwks, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return v.LoadKeys(jwks)
+}
+
+// Verify verifies the given token and returns the principal to which it was issued.
+func (v *JWTVerifier) Verify(_ context.Context, token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return Principal{}, fmt.Errorf("malformed token header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("malformed token signature: %v", err)
+	}
+	if !v.keys.verify(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), signature) {
+		return Principal{}, fmt.Errorf("invalid token signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("malformed token claims: %v", err)
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return Principal{}, err
+	}
+
+	principal := Principal{
+		Type:   AuthenticationTypeOauthBearerToken,
+		Scopes: jwtScopes(claims),
+		Claims: claims,
+	}
+	principal.ID, _ = claims["sub"].(string)
+	for _, scope := range v.Scopes {
+		if !contains(principal.Scopes, scope) {
+			return Principal{}, errors.ScimError{
+				Detail: fmt.Sprintf("The token does not grant the required scope %q.", scope),
+				Status: http.StatusForbidden,
+			}
+		}
+	}
+	return principal, nil
+}
+
+// validateClaims validates the registered claims of a token.
+func (v *JWTVerifier) validateClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+
+	if exp, ok := claims["exp"]; ok {
+		t, ok := numericDate(exp)
+		if !ok {
+			return fmt.Errorf("invalid \"exp\" claim")
+		}
+		if !now.Before(t.Add(v.Leeway)) {
+			return fmt.Errorf("token is expired")
+		}
+	} else if !v.AllowMissingExpiration {
+		return fmt.Errorf("token has no \"exp\" claim")
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		t, ok := numericDate(nbf)
+		if !ok {
+			return fmt.Errorf("invalid \"nbf\" claim")
+		}
+		if now.Add(v.Leeway).Before(t) {
+			return fmt.Errorf("token is not valid yet")
+		}
+	}
+	if v.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Issuer {
+			return fmt.Errorf("invalid token issuer")
+		}
+	}
+	if v.Audience != "" {
+		var audience []string
+		switch aud := claims["aud"].(type) {
+		case string:
+			audience = []string{aud}
+		case []interface{}:
+			for _, a := range aud {
+				if a, ok := a.(string); ok {
+					audience = append(audience, a)
+				}
+			}
+		}
+		if !contains(audience, v.Audience) {
+			return fmt.Errorf("invalid token audience")
+		}
+	}
+	return nil
+}
+
+// decodeJWTPart decodes the given base64url encoded part of a token into the given value.
+func decodeJWTPart(part string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// numericDate returns the time of the given "NumericDate" claim value, i.e. the number of seconds since the epoch.
+func numericDate(value interface{}) (time.Time, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// jwtScopes returns the scopes that are granted by the "scope" claim of the given claims, which is a space-separated
+// list of scopes. Some issuers use a "scp" claim instead, which is either a string or an array of strings.
+func jwtScopes(claims map[string]interface{}) []string {
+	var scopes []string
+	for _, name := range []string{"scope", "scp"} {
+		switch scope := claims[name].(type) {
+		case string:
+			scopes = append(scopes, strings.Fields(scope)...)
+		case []interface{}:
+			for _, s := range scope {
+				if s, ok := s.(string); ok {
+					scopes = append(scopes, s)
+				}
+			}
+		}
+	}
+	return scopes
+}
+
+// jwk is a JSON Web Key that can be used to verify the signature of a token.
+// More info: https://datatracker.ietf.org/doc/html/rfc7517
+type jwk struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+// jwkSet is a reloadable set of JSON Web Keys.
+type jwkSet struct {
+	mu   sync.RWMutex
+	keys []jwk
+}
+
+// verify reports whether the given signature of the given signing input is valid for one of the keys in the set. Only
+// keys with the given key identifier (if not empty) and of the type that corresponds with the given algorithm are used.
+func (s *jwkSet) verify(alg, kid string, input, signature []byte) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	digest := sha256.Sum256(input)
+	for _, k := range s.keys {
+		if kid != "" && k.kid != kid {
+			continue
+		}
+		if k.alg != "" && k.alg != alg {
+			continue
+		}
+		switch key := k.key.(type) {
+		case *rsa.PublicKey:
+			if alg == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if alg == "ES256" && len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				rs := new(big.Int).SetBytes(signature[32:])
+				if ecdsa.Verify(key, digest[:], r, rs) {
+					return true
+				}
+			}
+		case []byte:
+			if alg == "HS256" {
+				mac := hmac.New(sha256.New, key)
+				mac.Write(input)
+				if hmac.Equal(signature, mac.Sum(nil)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// parseJWKS parses the keys of the given JWKS document. Keys that are not meant for signatures or that have an
+// unsupported key type are ignored.
+func parseJWKS(jwks []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %v", err)
+	}
+
+	var keys []jwk
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key := jwk{
+			kid: k.Kid,
+			alg: k.Alg,
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("invalid RSA key %d in JWKS document", i)
+			}
+			key.key = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				return nil, fmt.Errorf("invalid EC key %d in JWKS document", i)
+			}
+			key.key = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) == 0 {
+				return nil, fmt.Errorf("invalid symmetric key %d in JWKS document", i)
+			}
+			key.key = secret
+		default:
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("the JWKS document does not contain any supported signing keys")
+	}
+	return keys, nil
+}
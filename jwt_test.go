@@ -0,0 +1,231 @@
+package scim
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+type testJWTKeys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newTestJWTKeys(t *testing.T) testJWTKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testJWTKeys{
+		rsa:    rsaKey,
+		ec:     ecKey,
+		secret: []byte("0123456789abcdef0123456789abcdef"),
+	}
+}
+
+func (k testJWTKeys) jwks() []byte {
+	encode := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	raw, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"use": "sig",
+				"n":   encode(k.rsa.N.Bytes()),
+				"e":   encode(big.NewInt(int64(k.rsa.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   encode(k.ec.X.FillBytes(make([]byte, 32))),
+				"y":   encode(k.ec.Y.FillBytes(make([]byte, 32))),
+			},
+			{
+				"kty": "oct",
+				"kid": "hmac",
+				"alg": "HS256",
+				"k":   encode(k.secret),
+			},
+			{
+				"kty": "RSA",
+				"kid": "encryption",
+				"use": "enc",
+			},
+		},
+	})
+	return raw
+}
+
+func (k testJWTKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "HS256":
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTVerifier(t *testing.T) {
+	now := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	keys := newTestJWTKeys(t)
+	verifier, err := NewJWTVerifier(keys.jwks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.Issuer = "https://idp.example.com"
+	verifier.Audience = "scim"
+	verifier.Scopes = []string{"scim:write"}
+	verifier.Leeway = time.Minute
+	verifier.now = func() time.Time { return now }
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":   "client",
+			"iss":   "https://idp.example.com",
+			"aud":   []string{"other", "scim"},
+			"exp":   now.Add(time.Hour).Unix(),
+			"nbf":   now.Unix(),
+			"scope": "scim:read scim:write",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	tampered := keys.sign(t, "RS256", "rsa", claims(nil))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "RS256", token: keys.sign(t, "RS256", "rsa", claims(nil)), valid: true},
+		{name: "ES256", token: keys.sign(t, "ES256", "ec", claims(nil)), valid: true},
+		{name: "HS256", token: keys.sign(t, "HS256", "hmac", claims(nil)), valid: true},
+		{name: "no key identifier", token: keys.sign(t, "RS256", "", claims(nil)), valid: true},
+		{name: "string audience", token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"aud": "scim"})), valid: true},
+		{name: "scp claim", token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"scope": nil, "scp": []string{"scim:write"}})), valid: true},
+		{name: "within leeway", token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": now.Add(-time.Second).Unix()})), valid: true},
+		{name: "expired", token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), valid: false},
+		{name: "no expiration", token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": nil})), valid: false},
+		{name: "not yet valid", token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), valid: false},
+		{name: "issuer", token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"iss": "https://evil.example.com"})), valid: false},
+		{name: "audience", token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"aud": "other"})), valid: false},
+		{name: "unknown key", token: keys.sign(t, "RS256", "unknown", claims(nil)), valid: false},
+		{name: "algorithm mismatch", token: keys.sign(t, "HS256", "rsa", claims(nil)), valid: false},
+		{name: "none algorithm", token: keys.sign(t, "none", "", claims(nil)), valid: false},
+		{name: "tampered", token: tampered, valid: false},
+		{name: "malformed", token: "not.a.token", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := verifier.Verify(context.Background(), test.token)
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, "client", principal.ID)
+			assertEqual(t, AuthenticationTypeOauthBearerToken, principal.Type)
+			assertEqual(t, "https://idp.example.com", principal.Claims["iss"])
+		})
+	}
+
+	// Tokens without expiration are only accepted if explicitly allowed.
+	verifier.AllowMissingExpiration = true
+	if _, err := verifier.Verify(context.Background(), keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": nil}))); err != nil {
+		t.Error(err)
+	}
+	verifier.AllowMissingExpiration = false
+
+	// Tokens without the required scopes are forbidden.
+	_, err = verifier.Verify(context.Background(), keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"scope": "scim:read"})))
+	scimErr, ok := err.(errors.ScimError)
+	assertTypeOk(t, ok, "scim error")
+	assertEqual(t, http.StatusForbidden, scimErr.Status)
+}
+
+func TestJWTVerifierLoadKeys(t *testing.T) {
+	oldKeys, newKeys := newTestJWTKeys(t), newTestJWTKeys(t)
+	verifier, err := NewJWTVerifier(oldKeys.jwks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer()
+	s.Authenticators = []Authenticator{BearerTokenAuthenticator{Verify: verifier.Verify}}
+
+	get := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/Users/0001", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	claims := map[string]interface{}{"sub": "client", "exp": time.Now().Add(time.Hour).Unix()}
+	assertEqualStatusCode(t, http.StatusOK, get(oldKeys.sign(t, "ES256", "ec", claims)))
+	assertEqualStatusCode(t, http.StatusUnauthorized, get(newKeys.sign(t, "ES256", "ec", claims)))
+
+	// Invalid documents do not replace the current keys.
+	if err := verifier.LoadKeys([]byte(`{"keys": []}`)); err == nil {
+		t.Error("expected an error")
+	}
+	assertEqualStatusCode(t, http.StatusOK, get(oldKeys.sign(t, "ES256", "ec", claims)))
+
+	if err := verifier.LoadKeys(newKeys.jwks()); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualStatusCode(t, http.StatusUnauthorized, get(oldKeys.sign(t, "ES256", "ec", claims)))
+	assertEqualStatusCode(t, http.StatusOK, get(newKeys.sign(t, "ES256", "ec", claims)))
+}
@@ -12,7 +12,7 @@ type Page struct {
 	Resources []Resource
 }
 
-func (p Page) resources(resourceType ResourceType) []interface{} {
+func (p Page) resources(resourceType ResourceType, projection attributeProjection, baseURL string) []interface{} {
 	// If the page.Resources is nil, then it will also be represented as a `null` in the response.
 	// Otherwise is it is an empty slice then it will result in an empty array `[]`.
 	if len(p.Resources) == 0 {
@@ -26,7 +26,7 @@ func (p Page) resources(resourceType ResourceType) []interface{} {
 	for _, v := range p.Resources {
 		resources = append(
 			resources,
-			v.response(resourceType),
+			projection.apply(resourceType, v.response(resourceType, baseURL)),
 		)
 	}
 	return resources
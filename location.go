@@ -0,0 +1,98 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// baseURLKey is the context key of the base URL of the service provider.
+type baseURLKey struct{}
+
+// withBaseURL returns a copy of the given request that contains the base URL of the service provider, so that it does
+// not need to be derived again for requests that are derived from it (e.g., bulk operations).
+func (s Server) withBaseURL(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), baseURLKey{}, s.baseURL(r)))
+}
+
+// baseURL returns the base URL of the service provider without trailing slash, e.g., "https://example.com/v2". It is
+// empty if the base URL is unknown, in which case all locations are relative to the base URL.
+func (s Server) baseURL(r *http.Request) string {
+	if base, ok := r.Context().Value(baseURLKey{}).(string); ok {
+		return base
+	}
+	if s.BaseURL != "" {
+		return strings.TrimSuffix(s.BaseURL, "/")
+	}
+	if !s.DeriveBaseURL {
+		return ""
+	}
+
+	scheme, host, prefix := "http", r.Host, ""
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if s.TrustForwardedHeaders {
+		if forwarded := r.Header.Get("Forwarded"); forwarded != "" {
+			// Only the first element is used, which is added by the proxy that is closest to the client.
+			element := strings.SplitN(forwarded, ",", 2)[0]
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				value := strings.Trim(kv[1], "\"")
+				switch strings.ToLower(kv[0]) {
+				case "proto":
+					scheme = value
+				case "host":
+					host = value
+				}
+			}
+		} else {
+			if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto != "" {
+				scheme = proto
+			}
+			if forwardedHost := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHost != "" {
+				host = forwardedHost
+			}
+		}
+		prefix = strings.TrimSuffix(firstHeaderValue(r, "X-Forwarded-Prefix"), "/")
+	}
+	prefix += tenantPrefix(r)
+	if strings.HasPrefix(r.URL.Path, "/v2") {
+		prefix += "/v2"
+	}
+
+	return (&url.URL{
+		Scheme: strings.ToLower(scheme),
+		Host:   host,
+		Path:   prefix,
+	}).String()
+}
+
+// location returns the location of the given path relative to the base URL of the service provider of the given
+// request, e.g., "Users/{id}" becomes "https://example.com/v2/Users/{id}".
+func (s Server) location(r *http.Request, path string) string {
+	return joinLocation(s.baseURL(r), path)
+}
+
+// location returns the location of the resource with the given identifier relative to the given base URL.
+func (t ResourceType) location(baseURL, id string) string {
+	return joinLocation(baseURL, t.Endpoint+"/"+url.PathEscape(id))
+}
+
+// joinLocation joins the given base URL and path. The path itself is returned if the base URL is unknown, i.e. empty.
+func joinLocation(baseURL, path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if baseURL == "" {
+		return path
+	}
+	return baseURL + "/" + path
+}
+
+// firstHeaderValue returns the first of the comma separated values of the header with the given key.
+func firstHeaderValue(r *http.Request, key string) string {
+	return strings.TrimSpace(strings.SplitN(r.Header.Get(key), ",", 2)[0])
+}
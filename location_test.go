@@ -0,0 +1,113 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   Server
+		target   string
+		headers  map[string]string
+		expected string
+	}{
+		{
+			name:     "relative",
+			target:   "/v2/Users",
+			expected: "",
+		},
+		{
+			name:     "configured",
+			server:   Server{BaseURL: "https://example.com/scim/v2/", DeriveBaseURL: true},
+			target:   "/v2/Users",
+			expected: "https://example.com/scim/v2",
+		},
+		{
+			name:     "derived",
+			server:   Server{DeriveBaseURL: true},
+			target:   "http://example.com/v2/Users",
+			headers:  map[string]string{"X-Forwarded-Host": "scim.example.com"},
+			expected: "http://example.com/v2",
+		},
+		{
+			name:   "forwarded",
+			server: Server{DeriveBaseURL: true, TrustForwardedHeaders: true},
+			target: "http://10.0.0.1/Users",
+			headers: map[string]string{
+				"Forwarded":          `for=192.0.2.60;proto=https;host="scim.example.com", for=10.0.0.2`,
+				"X-Forwarded-Prefix": "/scim/",
+			},
+			expected: "https://scim.example.com/scim",
+		},
+		{
+			name:   "x-forwarded",
+			server: Server{DeriveBaseURL: true, TrustForwardedHeaders: true},
+			target: "http://10.0.0.1/v2/Users",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "HTTPS",
+				"X-Forwarded-Host":  "scim.example.com, 10.0.0.2",
+			},
+			expected: "https://scim.example.com/v2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			assertEqual(t, test.expected, test.server.baseURL(req))
+		})
+	}
+}
+
+func TestServerResourcePostHandlerLocation(t *testing.T) {
+	s := newTestServer()
+	s.BaseURL = "https://example.com/v2"
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/Users", strings.NewReader(`{"userName": "test1"}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusCreated, rr.Code)
+
+	var resource map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+	location := resource["meta"].(map[string]interface{})["location"]
+	assertEqual(t, "https://example.com/v2/Users/"+resource["id"].(string), location)
+	assertEqual(t, location, rr.Header().Get("Location"))
+}
+
+func TestServerDiscoveryLocation(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{target: "/v2/ServiceProviderConfig", expected: "https://example.com/v2/ServiceProviderConfig"},
+		{target: "/v2/ResourceTypes/User", expected: "https://example.com/v2/ResourceTypes/User"},
+		{
+			target:   "/v2/Schemas/urn:ietf:params:scim:schemas:core:2.0:User",
+			expected: "https://example.com/v2/Schemas/urn:ietf:params:scim:schemas:core:2.0:User",
+		},
+	}
+
+	s := newTestServer()
+	s.DeriveBaseURL = true
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://example.com"+test.target, nil)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+			var resource map[string]interface{}
+			assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+			assertEqual(t, test.expected, resource["meta"].(map[string]interface{})["location"])
+		})
+	}
+}
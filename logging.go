@@ -0,0 +1,173 @@
+package scim
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Logger writes structured log records. The arguments are alternating keys and values, e.g.,
+// Info("request", "status", 200). It is compatible with *slog.Logger of the log/slog package.
+type Logger interface {
+	// Info writes an informational log record with the given message and key-value pairs.
+	Info(msg string, args ...interface{})
+	// Error writes an error log record with the given message and key-value pairs.
+	Error(msg string, args ...interface{})
+}
+
+// defaultLogger writes error records with the standard logger of the log package, and discards informational records.
+type defaultLogger struct{}
+
+func (defaultLogger) Error(msg string, args ...interface{}) {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	log.Print(b.String())
+}
+
+func (defaultLogger) Info(string, ...interface{}) {}
+
+// logger returns the logger of the server.
+func (s Server) logger() Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return defaultLogger{}
+}
+
+// requestLogKey is the context key of the log record of a request.
+type requestLogKey struct{}
+
+// requestLog collects the fields of the log record of a request, which is written once the request is handled. The
+// same fields are passed to the observer of the server.
+type requestLog struct {
+	logger       Logger
+	endpoint     string
+	resourceType string
+	operation    Operation
+	scimType     string
+}
+
+// withRequestLog returns a copy of the given request that contains a new log record for the given logger.
+func withRequestLog(r *http.Request, logger Logger) (*http.Request, *requestLog) {
+	record := &requestLog{logger: logger}
+	return r.WithContext(context.WithValue(r.Context(), requestLogKey{}, record)), record
+}
+
+// requestLogFrom returns the log record of the given request, or nil if the request has none.
+func requestLogFrom(r *http.Request) *requestLog {
+	record, _ := r.Context().Value(requestLogKey{}).(*requestLog)
+	return record
+}
+
+// detachRequestLog returns a copy of the given context with a new log record for the same logger, so that requests
+// that are derived from the request of the context do not change its log record.
+func detachRequestLog(ctx context.Context) context.Context {
+	record, ok := ctx.Value(requestLogKey{}).(*requestLog)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, requestLogKey{}, &requestLog{logger: record.logger})
+}
+
+// setOperation sets the endpoint, the resource type and the operation in the log record of the given request.
+func setOperation(r *http.Request, endpoint string, resourceType ResourceType, op Operation) {
+	if record := requestLogFrom(r); record != nil {
+		record.endpoint = endpoint
+		record.resourceType = resourceType.Name
+		record.operation = op
+	}
+}
+
+// logError writes an error record with the logger of the given request.
+func logError(r *http.Request, msg string, err error) {
+	var logger Logger = defaultLogger{}
+	if record := requestLogFrom(r); record != nil {
+		logger = record.logger
+	}
+	logger.Error(msg, "method", r.Method, "path", r.URL.Path, "error", err)
+}
+
+// endRequest writes the log record of the given request, which resulted in a response with the given status after
+// the given duration, and notifies the observer of the server that the request has been handled.
+func (s Server) endRequest(ctx context.Context, r *http.Request, record *requestLog, status int, duration time.Duration) {
+	if status == 0 {
+		// Responses without body or explicit status code are sent as 200 OK.
+		status = http.StatusOK
+	}
+	record.write(r, status, duration)
+
+	if s.Observer != nil {
+		s.Observer.RequestEnd(ctx, RequestObservation{
+			Method:       r.Method,
+			Path:         r.URL.Path,
+			Endpoint:     record.endpoint,
+			ResourceType: record.resourceType,
+			Operation:    record.operation,
+			Status:       status,
+			ScimType:     record.scimType,
+			Duration:     duration,
+		})
+	}
+}
+
+// write writes the log record of the given request, which resulted in a response with the given status after the
+// given duration. Server errors are written as error records.
+func (l *requestLog) write(r *http.Request, status int, latency time.Duration) {
+	args := []interface{}{
+		"method", r.Method,
+		"path", r.URL.Path,
+		"endpoint", l.endpoint,
+		"resourceType", l.resourceType,
+		"operation", string(l.operation),
+		"status", status,
+		"scimType", l.scimType,
+		"latency", latency,
+	}
+	if status >= http.StatusInternalServerError {
+		l.logger.Error("scim request failed", args...)
+		return
+	}
+	l.logger.Info("scim request", args...)
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// operationOf returns the operation that corresponds with the given HTTP method on a resource endpoint.
+func operationOf(method string) Operation {
+	switch method {
+	case http.MethodPost:
+		return OperationCreate
+	case http.MethodPut:
+		return OperationReplace
+	case http.MethodPatch:
+		return OperationPatch
+	case http.MethodDelete:
+		return OperationDelete
+	default:
+		return OperationRead
+	}
+}
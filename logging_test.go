@@ -0,0 +1,106 @@
+package scim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testLogRecord struct {
+	level string
+	msg   string
+	attrs map[string]interface{}
+}
+
+type testLogger struct {
+	records *[]testLogRecord
+}
+
+func (l testLogger) Error(msg string, args ...interface{}) {
+	l.log("error", msg, args)
+}
+
+func (l testLogger) Info(msg string, args ...interface{}) {
+	l.log("info", msg, args)
+}
+
+func (l testLogger) log(level, msg string, args []interface{}) {
+	attrs := make(map[string]interface{})
+	for i := 0; i+1 < len(args); i += 2 {
+		attrs[args[i].(string)] = args[i+1]
+	}
+	*l.records = append(*l.records, testLogRecord{level: level, msg: msg, attrs: attrs})
+}
+
+// testUnmarshalableHandler returns resources that can not be marshaled.
+type testUnmarshalableHandler struct {
+	testResourceHandler
+}
+
+func (h testUnmarshalableHandler) Get(r *http.Request, id string) (Resource, error) {
+	return Resource{
+		ID:         id,
+		Attributes: ResourceAttributes{"userName": make(chan int)},
+	}, nil
+}
+
+func TestServerLogger(t *testing.T) {
+	var records []testLogRecord
+	s := newTestServer()
+	s.Logger = testLogger{records: &records}
+
+	tests := []struct {
+		name               string
+		method             string
+		target             string
+		body               string
+		expectedStatusCode int
+		expectedScimType   string
+		expectedOperation  string
+	}{
+		{name: "get", method: http.MethodGet, target: "/Users/0001", expectedStatusCode: http.StatusOK, expectedOperation: "read"},
+		{name: "not found", method: http.MethodGet, target: "/Users/9999", expectedStatusCode: http.StatusNotFound, expectedOperation: "read"},
+		{name: "search", method: http.MethodPost, target: "/Users/.search", body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"]}`, expectedStatusCode: http.StatusOK, expectedOperation: "read"},
+		{name: "invalid syntax", method: http.MethodPost, target: "/Users", body: `{`, expectedStatusCode: http.StatusBadRequest, expectedScimType: "invalidSyntax", expectedOperation: "create"},
+		{name: "delete", method: http.MethodDelete, target: "/Users/0002", expectedStatusCode: http.StatusNoContent, expectedOperation: "delete"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records = nil
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+
+			assertLen(t, records, 1)
+			record := records[0]
+			assertEqual(t, "info", record.level)
+			assertEqual(t, "User", record.attrs["resourceType"])
+			assertEqual(t, test.expectedOperation, record.attrs["operation"])
+			assertEqual(t, test.expectedStatusCode, record.attrs["status"])
+			assertEqual(t, test.expectedScimType, record.attrs["scimType"])
+			assertNotNil(t, record.attrs["latency"], "latency")
+		})
+	}
+}
+
+func TestServerLoggerMarshalFailure(t *testing.T) {
+	var records []testLogRecord
+	s := newTestServer()
+	s.Logger = testLogger{records: &records}
+	s.ResourceTypes[0].Handler = testUnmarshalableHandler{
+		testResourceHandler: newTestResourceHandler().(testResourceHandler),
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/Users/0001", nil))
+	assertEqualStatusCode(t, http.StatusInternalServerError, rr.Code)
+
+	assertLen(t, records, 2)
+	assertEqual(t, "error", records[0].level)
+	assertEqual(t, "failed marshaling resource", records[0].msg)
+	assertNotNil(t, records[0].attrs["error"], "error")
+	assertEqual(t, "error", records[1].level)
+	assertEqual(t, http.StatusInternalServerError, records[1].attrs["status"])
+}
@@ -0,0 +1,92 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// MeResolver resolves the resource of the authenticated subject of the given request, i.e. the resource to which the
+// "/Me" alias refers. It returns the name of the resource type and the identifier of the resource. If the subject has
+// no corresponding resource, a 404 Not Found SCIM error should be returned. The subject that was authenticated by the
+// authenticators of the server can be retrieved with PrincipalFromContext.
+// More info: https://datatracker.ietf.org/doc/html/rfc7644#section-3.11
+type MeResolver func(r *http.Request) (resourceType string, id string, err error)
+
+// meHandler receives an HTTP GET, PUT, PATCH or DELETE request to the "/Me" endpoint, which is an alias for the
+// resource of the authenticated subject. The request is handled by the resource type to which the subject belongs.
+func (s Server) meHandler(w http.ResponseWriter, r *http.Request) {
+	if s.MeResolver == nil {
+		errorHandler(w, r, &errors.ScimError{
+			Status: http.StatusNotImplemented,
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		errorHandler(w, r, &errors.ScimError{
+			Detail: fmt.Sprintf("The %s-operation is not supported on the /Me endpoint.", r.Method),
+			Status: http.StatusNotImplemented,
+		})
+		return
+	}
+
+	name, id, err := s.MeResolver(r)
+	if err != nil {
+		scimErr := errors.CheckScimError(err, r.Method)
+		errorHandler(w, r, &scimErr)
+		return
+	}
+
+	resourceType, ok := s.getResourceType(name)
+	if !ok {
+		errorHandler(w, r, &errors.ScimError{
+			Detail: fmt.Sprintf("The resource type %s of the authenticated subject does not exist.", name),
+			Status: http.StatusInternalServerError,
+		})
+		return
+	}
+
+	// The location of the resource is only returned if the request succeeds, e.g., not if the resource does not exist.
+	w = &locationWriter{ResponseWriter: w, location: resourceType.location(s.baseURL(r), id)}
+	setOperation(r, "/Me", resourceType, operationOf(r.Method))
+
+	switch r.Method {
+	case http.MethodGet:
+		s.resourceGetHandler(w, r, id, resourceType)
+	case http.MethodPut:
+		s.resourcePutHandler(w, r, id, resourceType)
+	case http.MethodPatch:
+		s.resourcePatchHandler(w, r, id, resourceType)
+	case http.MethodDelete:
+		s.resourceDeleteHandler(w, r, id, resourceType)
+	}
+}
+
+// locationWriter sets the "Location" header of a response when its status code is written, unless the status code
+// indicates an error.
+type locationWriter struct {
+	http.ResponseWriter
+	location    string
+	wroteHeader bool
+}
+
+func (w *locationWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *locationWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status < http.StatusBadRequest {
+			w.Header().Set("Location", w.location)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,106 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+func TestServerMeHandler(t *testing.T) {
+	tests := []struct {
+		name               string
+		method             string
+		target             string
+		body               string
+		expectedStatusCode int
+	}{
+		{name: "get", method: http.MethodGet, target: "/Me", expectedStatusCode: http.StatusOK},
+		{name: "get v2", method: http.MethodGet, target: "/v2/Me", expectedStatusCode: http.StatusOK},
+		{name: "put", method: http.MethodPut, target: "/Me", body: `{"userName": "other"}`, expectedStatusCode: http.StatusOK},
+		{
+			name:               "patch",
+			method:             http.MethodPatch,
+			target:             "/Me",
+			body:               `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "userName", "value": "other"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{name: "delete", method: http.MethodDelete, target: "/Me", expectedStatusCode: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set("Authorization", "Bearer 0001")
+			rr := httptest.NewRecorder()
+			s := newTestServer()
+			s.MeResolver = testMeResolver
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+			// The location is relative to the base URL, i.e. the parent of the "/Me" endpoint.
+			assertEqual(t, "Users/0001", rr.Header().Get("Location"))
+			if test.method == http.MethodDelete {
+				return
+			}
+
+			var resource map[string]interface{}
+			assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+			assertEqual(t, "0001", resource["id"])
+		})
+	}
+}
+
+func TestServerMeHandlerInvalid(t *testing.T) {
+	tests := []struct {
+		name               string
+		method             string
+		subject            string
+		ifMatch            string
+		expectedStatusCode int
+	}{
+		{name: "no subject", method: http.MethodGet, expectedStatusCode: http.StatusNotFound},
+		{name: "unknown resource", method: http.MethodGet, subject: "9999", expectedStatusCode: http.StatusNotFound},
+		{name: "unknown resource type", method: http.MethodGet, subject: "unknown", expectedStatusCode: http.StatusInternalServerError},
+		{name: "post", method: http.MethodPost, subject: "0001", expectedStatusCode: http.StatusNotImplemented},
+		{name: "delete unknown resource", method: http.MethodDelete, subject: "9999", expectedStatusCode: http.StatusNotFound},
+		{name: "precondition failed", method: http.MethodDelete, subject: "0001", ifMatch: `W/"other"`, expectedStatusCode: http.StatusPreconditionFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/Me", nil)
+			if test.subject != "" {
+				req.Header.Set("Authorization", "Bearer "+test.subject)
+			}
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			s := newTestServer()
+			s.MeResolver = testMeResolver
+			s.Config.SupportETag = true
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+			// Error responses do not refer to a resource.
+			assertEqual(t, "", rr.Header().Get("Location"))
+		})
+	}
+}
+
+// testMeResolver resolves the subject of a request from its bearer token, which contains the identifier of the user.
+// The identifier "unknown" refers to an unknown resource type.
+func testMeResolver(r *http.Request) (string, string, error) {
+	id := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	switch id {
+	case "":
+		return "", "", errors.ScimErrorResourceNotFound("Me")
+	case "unknown":
+		return "Unknown", id, nil
+	}
+	return "User", id, nil
+}
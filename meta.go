@@ -15,4 +15,7 @@ type meta struct {
 	// Version is the version of the resource being returned. This value must be the same as the entity-tag (ETag) HTTP
 	// response header.
 	Version string `json:"version,omitempty"`
+	// Location is the URI of the resource being returned. This value must be the same as the "Location" HTTP response
+	// header (if present).
+	Location string `json:"location,omitempty"`
 }
@@ -0,0 +1,200 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// Phase is a phase in the handling of a request that is observed by an Observer.
+type Phase string
+
+const (
+	// PhaseValidation validates the body of a create, replace or patch request against the schemas of the resource
+	// type.
+	PhaseValidation Phase = "validation"
+	// PhaseFilterParsing parses and validates the filter and the other parameters of a list or search request.
+	PhaseFilterParsing Phase = "filterParsing"
+	// PhaseHandler calls one of the methods of the ResourceHandler of the resource type.
+	PhaseHandler Phase = "handler"
+)
+
+// RequestObservation describes a request that is handled by the server. The fields that describe the result of the
+// request are only set when the request ends.
+type RequestObservation struct {
+	// Method is the HTTP method of the request.
+	Method string
+	// Path is the path of the request.
+	Path string
+	// Endpoint is the endpoint that handled the request, without identifiers, e.g., "/Users/{id}" or "/Schemas". It is
+	// suitable to group requests, e.g., as the label of a metric.
+	Endpoint string
+	// ResourceType is the name of the resource type of the request, if any.
+	ResourceType string
+	// Operation is the operation of the request on the resource type, if any.
+	Operation Operation
+	// Status is the status code of the response.
+	Status int
+	// ScimType is the SCIM error type of the response, if any.
+	ScimType string
+	// Duration is the time it took to handle the request.
+	Duration time.Duration
+}
+
+// PhaseObservation describes a phase in the handling of a request. The fields that describe the result of the phase
+// are only set when the phase ends.
+type PhaseObservation struct {
+	// Phase is the phase of the request.
+	Phase Phase
+	// ResourceType is the name of the resource type of the request, if any.
+	ResourceType string
+	// HandlerMethod is the name of the method of the resource handler that is called, e.g., "GetAll". It is only set
+	// for the handler phase.
+	HandlerMethod string
+	// Err is the error that resulted from the phase, if any.
+	Err error
+	// Duration is the time it took to complete the phase.
+	Duration time.Duration
+}
+
+// Observer observes the handling of requests, e.g., to collect metrics or to record tracing spans. The start methods
+// return the context that is used for the request or phase, e.g., to pass a tracing span to the resource handlers. The
+// end methods are called with that context. See PrometheusObserver for a ready-made observer that collects metrics.
+type Observer interface {
+	// RequestStart is called when the server starts handling a request.
+	RequestStart(ctx context.Context, request RequestObservation) context.Context
+	// RequestEnd is called when the server has handled a request.
+	RequestEnd(ctx context.Context, request RequestObservation)
+	// PhaseStart is called when a phase of a request starts.
+	PhaseStart(ctx context.Context, phase PhaseObservation) context.Context
+	// PhaseEnd is called when a phase of a request ends.
+	PhaseEnd(ctx context.Context, phase PhaseObservation)
+}
+
+// startPhase notifies the observer of the server that the given phase of the given request starts. It returns the
+// request with the context of the phase, and the function that needs to be called when the phase ends.
+func (s Server) startPhase(r *http.Request, phase PhaseObservation) (*http.Request, func(err error)) {
+	return startPhase(s.Observer, r, phase)
+}
+
+// startPhase notifies the given observer that the given phase of the given request starts, see Server.startPhase.
+func startPhase(observer Observer, r *http.Request, phase PhaseObservation) (*http.Request, func(err error)) {
+	if observer == nil {
+		return r, func(error) {}
+	}
+
+	start := time.Now()
+	ctx := observer.PhaseStart(r.Context(), phase)
+	return r.WithContext(ctx), func(err error) {
+		phase.Err = err
+		phase.Duration = time.Since(start)
+		observer.PhaseEnd(ctx, phase)
+	}
+}
+
+// scimError returns the given SCIM error as an error, or nil if there is no error.
+func scimError(scimErr *errors.ScimError) error {
+	if scimErr == nil {
+		return nil
+	}
+	return *scimErr
+}
+
+// observed returns a copy of the server of which the handlers of the resource types are observed by the observer of
+// the server.
+func (s Server) observed() Server {
+	if s.Observer == nil {
+		return s
+	}
+
+	resourceTypes := make([]ResourceType, len(s.ResourceTypes))
+	for i, resourceType := range s.ResourceTypes {
+		resourceType.Handler = observedHandler{
+			observer:     s.Observer,
+			resourceType: resourceType.Name,
+			handler:      resourceType.Handler,
+		}
+		resourceTypes[i] = resourceType
+	}
+	s.ResourceTypes = resourceTypes
+	return s
+}
+
+// observedHandler is a resource handler of which the method calls are observed as handler phases.
+type observedHandler struct {
+	observer     Observer
+	resourceType string
+	handler      ResourceHandler
+}
+
+func (h observedHandler) Create(r *http.Request, attributes ResourceAttributes) (Resource, error) {
+	r, end := h.start(r, "Create")
+	resource, err := h.handler.Create(r, attributes)
+	end(err)
+	return resource, err
+}
+
+func (h observedHandler) Delete(r *http.Request, id string) error {
+	r, end := h.start(r, "Delete")
+	err := h.handler.Delete(r, id)
+	end(err)
+	return err
+}
+
+func (h observedHandler) Get(r *http.Request, id string) (Resource, error) {
+	r, end := h.start(r, "Get")
+	resource, err := h.handler.Get(r, id)
+	end(err)
+	return resource, err
+}
+
+func (h observedHandler) GetAll(r *http.Request, params ListRequestParams) (Page, error) {
+	r, end := h.start(r, "GetAll")
+	page, err := h.handler.GetAll(r, params)
+	end(err)
+	return page, err
+}
+
+func (h observedHandler) Patch(r *http.Request, id string, operations []PatchOperation) (Resource, error) {
+	r, end := h.start(r, "Patch")
+	resource, err := h.handler.Patch(r, id, operations)
+	end(err)
+	return resource, err
+}
+
+func (h observedHandler) Replace(r *http.Request, id string, attributes ResourceAttributes) (Resource, error) {
+	r, end := h.start(r, "Replace")
+	resource, err := h.handler.Replace(r, id, attributes)
+	end(err)
+	return resource, err
+}
+
+func (h observedHandler) start(r *http.Request, method string) (*http.Request, func(err error)) {
+	return startPhase(h.observer, r, PhaseObservation{
+		Phase:         PhaseHandler,
+		ResourceType:  h.resourceType,
+		HandlerMethod: method,
+	})
+}
+
+// endpointPattern returns the endpoint to which the given path refers, without identifiers. The endpoints of resource
+// types are set by the router, see setOperation.
+func endpointPattern(path string) string {
+	switch {
+	case path == "" || path == "/":
+		return "/"
+	case strings.HasPrefix(path, "/Schemas/"):
+		return "/Schemas/{id}"
+	case strings.HasPrefix(path, "/ResourceTypes/"):
+		return "/ResourceTypes/{id}"
+	}
+	for _, endpoint := range []string{"/Me", "/Schemas", "/ResourceTypes", "/ServiceProviderConfig", "/Bulk", "/.search"} {
+		if path == endpoint {
+			return endpoint
+		}
+	}
+	return "unknown"
+}
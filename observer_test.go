@@ -0,0 +1,152 @@
+package scim
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testSpanKey struct{}
+
+// testObserver records the observed requests and phases as spans.
+type testObserver struct {
+	spans *[]string
+}
+
+func (o testObserver) PhaseEnd(ctx context.Context, phase PhaseObservation) {
+	span, _ := ctx.Value(testSpanKey{}).(string)
+	if phase.Err != nil {
+		span += " (failed)"
+	}
+	*o.spans = append(*o.spans, "end "+span)
+}
+
+func (o testObserver) PhaseStart(ctx context.Context, phase PhaseObservation) context.Context {
+	span := string(phase.Phase) + " " + phase.ResourceType + " " + phase.HandlerMethod
+	*o.spans = append(*o.spans, "start "+strings.TrimSpace(span))
+	return context.WithValue(ctx, testSpanKey{}, strings.TrimSpace(span))
+}
+
+func (o testObserver) RequestEnd(ctx context.Context, request RequestObservation) {
+	span, _ := ctx.Value(testSpanKey{}).(string)
+	*o.spans = append(*o.spans, "end "+span+" "+request.Endpoint+" "+string(request.Operation))
+}
+
+func (o testObserver) RequestStart(ctx context.Context, request RequestObservation) context.Context {
+	span := request.Method + " " + request.Path
+	*o.spans = append(*o.spans, "start "+span)
+	return context.WithValue(ctx, testSpanKey{}, span)
+}
+
+func TestServerObserver(t *testing.T) {
+	var spans []string
+	s := newTestServer()
+	s.Observer = testObserver{spans: &spans}
+
+	tests := []struct {
+		name          string
+		method        string
+		target        string
+		body          string
+		expectedSpans []string
+	}{
+		{
+			name:   "create",
+			method: http.MethodPost,
+			target: "/Users",
+			body:   `{"userName": "new"}`,
+			expectedSpans: []string{
+				"start POST /Users",
+				"start validation User",
+				"end validation User",
+				"start handler User Create",
+				"end handler User Create",
+				"end POST /Users /Users create",
+			},
+		},
+		{
+			name:   "invalid filter",
+			method: http.MethodGet,
+			target: "/Users?filter=invalid",
+			expectedSpans: []string{
+				"start GET /Users",
+				"start filterParsing User",
+				"end filterParsing User (failed)",
+				"end GET /Users /Users read",
+			},
+		},
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			target: "/Users/9999",
+			expectedSpans: []string{
+				"start GET /Users/9999",
+				"start handler User Get",
+				"end handler User Get (failed)",
+				"end GET /Users/9999 /Users/{id} read",
+			},
+		},
+		{
+			name:   "discovery",
+			method: http.MethodGet,
+			target: "/v2/Schemas",
+			expectedSpans: []string{
+				"start GET /v2/Schemas",
+				"end GET /v2/Schemas /Schemas ",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spans = nil
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+			assertEqualStrings(t, test.expectedSpans, spans)
+		})
+	}
+}
+
+func TestPrometheusObserver(t *testing.T) {
+	observer := NewPrometheusObserver()
+	s := newTestServer()
+	s.Observer = observer
+
+	for _, target := range []string{"/Users/0001", "/Users/0002", "/Users/9999", "/Users?filter=invalid"} {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	server := httptest.NewServer(observer)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	assertEqual(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"))
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := string(raw)
+
+	for _, line := range []string{
+		"# TYPE scim_requests_total counter",
+		`scim_requests_total{method="GET",endpoint="/Users/{id}",status="200",scim_type=""} 2`,
+		`scim_requests_total{method="GET",endpoint="/Users/{id}",status="404",scim_type=""} 1`,
+		`scim_requests_total{method="GET",endpoint="/Users",status="400",scim_type="invalidFilter"} 1`,
+		"# TYPE scim_request_duration_seconds histogram",
+		`scim_request_duration_seconds_bucket{method="GET",endpoint="/Users/{id}",le="+Inf"} 3`,
+		`scim_request_duration_seconds_count{method="GET",endpoint="/Users/{id}"} 3`,
+		`scim_phase_duration_seconds_count{phase="handler",resource_type="User",handler_method="Get"} 3`,
+		`scim_phase_errors_total{phase="handler",resource_type="User",handler_method="Get"} 1`,
+		`scim_phase_errors_total{phase="filterParsing",resource_type="User",handler_method=""} 1`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("missing metric line: %s", line)
+		}
+	}
+}
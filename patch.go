@@ -1,6 +1,18 @@
 package scim
 
-import "github.com/scim2/filter-parser/v2"
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+	f "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/internal/numeric"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
 
 const (
 	// PatchOperationAdd is used to add a new attribute value to an existing resource.
@@ -21,3 +33,672 @@ type PatchOperation struct {
 	// Value specifies the value to be added or replaced.
 	Value interface{}
 }
+
+// ApplyPatch applies the given operations to the attributes of a resource with the given schema and schema extensions,
+// as described in Section 3.5.2 of RFC 7644. The operations are expected to be validated, like the ones that are passed
+// to ResourceHandler.Patch. Attributes of schema extensions are expected to be nested under the schema URI.
+//
+// It returns the patched attributes and whether the operations changed the attributes at all, which can be used to
+// return a 204 No Content response. The given attributes are never modified. The operations are applied in order and
+// evaluation stops at the first operation that fails, in which case a SCIM error is returned, e.g., "noTarget" if a
+// value selection filter did not match any values, "mutability" if a read-only or immutable attribute is modified or
+// "invalidValue" if a required attribute is removed.
+// More info: https://datatracker.ietf.org/doc/html/rfc7644#section-3.5.2
+func ApplyPatch(attributes ResourceAttributes, operations []PatchOperation, s schema.Schema, extensions ...schema.Schema) (ResourceAttributes, bool, error) {
+	p := patcher{
+		schema:     s,
+		extensions: extensions,
+	}
+	patched := copyValue(map[string]interface{}(attributes)).(map[string]interface{})
+	for _, op := range operations {
+		if err := p.apply(patched, op); err != nil {
+			return attributes, false, err
+		}
+	}
+	return patched, !equalValues(map[string]interface{}(attributes), patched), nil
+}
+
+// patchByReplace applies the given operations to the resource with the given identifier by retrieving the resource,
+// applying the operations and replacing the resource with the result. The result is validated against the schemas of
+// the resource type before it is replaced. No resource attributes are returned if the operations did not change the
+// resource, so that a 204 No Content response is returned.
+func (t ResourceType) patchByReplace(r *http.Request, id string, operations []PatchOperation) (Resource, error) {
+	resource, err := t.Handler.Get(r, id)
+	if err != nil {
+		return Resource{}, err
+	}
+
+	// The resource could have been modified after the preconditions of the request were evaluated.
+	if version, ok := ExpectedVersion(r); ok && version != resource.Meta.Version {
+		return Resource{}, errors.ScimErrorPreconditionFailed(id)
+	}
+
+	attributes := ResourceAttributes{}
+	for k, v := range resource.Attributes {
+		attributes[k] = v
+	}
+	if resource.ExternalID.Present() {
+		attributes[schema.CommonAttributeExternalID] = resource.ExternalID.Value()
+	}
+
+	patched, changed, err := ApplyPatch(attributes, operations, t.Schema, t.getSchemaExtensions()...)
+	if err != nil {
+		return Resource{}, err
+	}
+	if !changed {
+		return Resource{}, nil
+	}
+
+	// The patched attributes are validated like the attributes of a PUT request, e.g., so that required attributes
+	// can not be removed.
+	raw, err := json.Marshal(patched)
+	if err != nil {
+		return Resource{}, err
+	}
+	validated, scimErr := t.validate(raw)
+	if scimErr != nil {
+		return Resource{}, *scimErr
+	}
+
+	if resource.Meta.Version != "" {
+		r = r.WithContext(withExpectedVersion(r.Context(), resource.Meta.Version))
+	}
+	return t.Handler.Replace(r, id, validated)
+}
+
+// patcher applies PATCH operations to the attributes of a resource with the given schema and schema extensions.
+type patcher struct {
+	schema     schema.Schema
+	extensions []schema.Schema
+}
+
+// apply applies the given operation to the given attributes.
+func (p patcher) apply(attributes map[string]interface{}, op PatchOperation) error {
+	op.Op = strings.ToLower(op.Op)
+	switch op.Op {
+	case PatchOperationAdd, PatchOperationReplace:
+	case PatchOperationRemove:
+		// If "path" is unspecified, the operation fails with HTTP status code 400 and a "scimType" error code of
+		// "noTarget".
+		if op.Path == nil {
+			return errors.ScimErrorNoTarget
+		}
+	default:
+		return errors.ScimErrorInvalidSyntax
+	}
+
+	// If "path" is omitted, the target location is assumed to be the resource itself. The "value" parameter contains
+	// a set of attributes to be added to or replaced in the resource.
+	if op.Path == nil {
+		value, ok := asMap(op.Value)
+		if !ok {
+			return errors.ScimErrorInvalidValue
+		}
+		for k, v := range value {
+			path, err := p.parsePath(k)
+			if err != nil {
+				return err
+			}
+			// e.g. "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": "701984"}
+			if path == nil {
+				extensionValue, ok := asMap(v)
+				if !ok {
+					return errors.ScimErrorInvalidValue
+				}
+				for name, v := range extensionValue {
+					uri := k
+					if err := p.apply(attributes, PatchOperation{
+						Op:    op.Op,
+						Path:  &filter.Path{AttributePath: filter.AttributePath{URIPrefix: &uri, AttributeName: name}},
+						Value: v,
+					}); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := p.apply(attributes, PatchOperation{Op: op.Op, Path: path, Value: v}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ref, attr, ok := getAttribute(op.Path.AttributePath, p.schema, p.extensions...)
+	if !ok {
+		return errors.ScimErrorInvalidPath
+	}
+
+	// Attributes of schema extensions are nested under the schema URI.
+	container := attributes
+	if ref.ID != p.schema.ID {
+		key := findKey(attributes, ref.ID)
+		if container, ok = asMap(attributes[key]); !ok {
+			if op.Op == PatchOperationRemove {
+				return removeUnassigned(*op.Path)
+			}
+			container = make(map[string]interface{})
+		}
+		defer func() {
+			if len(container) == 0 {
+				delete(attributes, key)
+				return
+			}
+			attributes[key] = container
+		}()
+	}
+
+	if op.Op == PatchOperationRemove {
+		return p.remove(container, ref, attr, *op.Path, op.Value)
+	}
+	return p.update(container, ref, attr, *op.Path, op.Value, op.Op == PatchOperationReplace)
+}
+
+// parsePath parses the given attribute name within the value of an operation without "path". It returns nil if the
+// name refers to a schema extension as a whole.
+func (p patcher) parsePath(name string) (*filter.Path, error) {
+	for _, extension := range p.extensions {
+		if strings.EqualFold(extension.ID, name) {
+			return nil, nil
+		}
+	}
+	path, err := filter.ParsePath([]byte(name))
+	if err != nil {
+		return nil, errors.ScimErrorInvalidPath
+	}
+	return &path, nil
+}
+
+// update applies an "add" or "replace" operation, based on Sections 3.5.2.1 and 3.5.2.3 of RFC 7644, to the attribute
+// of the given container. The value is expected to be validated against the targeted attribute.
+func (p patcher) update(container map[string]interface{}, ref schema.Schema, attr schema.CoreAttribute, path filter.Path, value interface{}, replace bool) error {
+	if attributeMutability(attr) == "readOnly" {
+		return errors.ScimErrorMutability
+	}
+
+	var (
+		key          = findKey(container, attr.Name())
+		current      = container[key]
+		subAttrName  = subAttributeName(path)
+		subAttribute schema.CoreAttribute
+	)
+	if subAttrName != "" {
+		var ok bool
+		if subAttribute, ok = attr.SubAttributes().ContainsAttribute(subAttrName); !ok {
+			return errors.ScimErrorInvalidPath
+		}
+	}
+
+	var updated interface{}
+	switch {
+	// e.g. emails[type eq "work"] or emails[type eq "work"].value
+	case path.ValueExpression != nil:
+		elements, _ := copyValue(current).([]interface{})
+		var matched bool
+		for i, element := range elements {
+			matches, err := matchesValueFilter(ref, attr, path.ValueExpression, element)
+			if err != nil {
+				return err
+			}
+			if !matches {
+				continue
+			}
+			matched = true
+
+			if subAttrName != "" {
+				complexValue, ok := asMap(element)
+				if !ok {
+					return errors.ScimErrorInvalidValue
+				}
+				if err := setSubAttribute(complexValue, subAttribute, value, replace); err != nil {
+					return err
+				}
+				continue
+			}
+
+			newElement := firstValue(value)
+			if replace {
+				elements[i] = newElement
+			} else {
+				elements[i] = mergeValue(attr, element, newElement)
+			}
+			setPrimary(elements, i)
+		}
+		// If the target location is a multi-valued attribute for which a value selection filter has been supplied and
+		// no record match was made, the operation fails with a "noTarget" error.
+		if !matched {
+			return errors.ScimErrorNoTarget
+		}
+		updated = elements
+	// e.g. name.givenName or emails.type
+	case subAttrName != "":
+		if attr.MultiValued() {
+			elements, _ := copyValue(current).([]interface{})
+			if len(elements) == 0 {
+				return errors.ScimErrorNoTarget
+			}
+			for _, element := range elements {
+				complexValue, ok := asMap(element)
+				if !ok {
+					return errors.ScimErrorInvalidValue
+				}
+				if err := setSubAttribute(complexValue, subAttribute, value, replace); err != nil {
+					return err
+				}
+			}
+			updated = elements
+			break
+		}
+		complexValue, ok := asMap(copyValue(current))
+		if !ok {
+			complexValue = make(map[string]interface{})
+		}
+		if err := setSubAttribute(complexValue, subAttribute, value, replace); err != nil {
+			return err
+		}
+		updated = complexValue
+	// If the target location specifies a single-valued attribute, the existing value is replaced. If the target
+	// location specifies a complex attribute, the sub-attributes that are not specified are left unchanged.
+	case !attr.MultiValued():
+		updated = mergeValue(attr, copyValue(current), value)
+	// If the target location specifies a multi-valued attribute, all values are replaced.
+	case replace:
+		elements := toSlice(value)
+		for i := range elements {
+			setPrimary(elements, i)
+		}
+		updated = elements
+	// If the target location specifies a multi-valued attribute, the new values are added to the existing values.
+	// Values that already exist are not added again.
+	default:
+		elements, _ := copyValue(current).([]interface{})
+		for _, v := range toSlice(value) {
+			if containsValue(elements, v) {
+				continue
+			}
+			elements = append(elements, v)
+			setPrimary(elements, len(elements)-1)
+		}
+		updated = elements
+	}
+
+	// Immutable attributes can only be added if no value exists yet.
+	if attributeMutability(attr) == "immutable" && current != nil && !equalValues(current, updated) {
+		return errors.ScimErrorMutability
+	}
+	container[key] = updated
+	return nil
+}
+
+// remove applies a "remove" operation, based on Section 3.5.2.2 of RFC 7644, to the attribute of the given container.
+// Required attributes can not be removed.
+func (p patcher) remove(container map[string]interface{}, ref schema.Schema, attr schema.CoreAttribute, path filter.Path, value interface{}) error {
+	key := findKey(container, attr.Name())
+	current, ok := container[key]
+	if !ok || current == nil {
+		return removeUnassigned(path)
+	}
+	if attributeMutability(attr) == "readOnly" || attributeMutability(attr) == "immutable" {
+		return errors.ScimErrorMutability
+	}
+
+	var (
+		subAttrName  = subAttributeName(path)
+		subAttribute schema.CoreAttribute
+	)
+	if subAttrName != "" {
+		if subAttribute, ok = attr.SubAttributes().ContainsAttribute(subAttrName); !ok {
+			return errors.ScimErrorInvalidPath
+		}
+	}
+
+	var remaining []interface{}
+	switch {
+	// If the target location is a multi-valued attribute and a complex filter is specified, the values matched by the
+	// filter are removed. If a sub-attribute is specified, only the sub-attribute of the matched values is removed.
+	case path.ValueExpression != nil:
+		elements, _ := copyValue(current).([]interface{})
+		var matched bool
+		for _, element := range elements {
+			matches, err := matchesValueFilter(ref, attr, path.ValueExpression, element)
+			if err != nil {
+				return err
+			}
+			if !matches {
+				remaining = append(remaining, element)
+				continue
+			}
+			matched = true
+			if subAttrName == "" {
+				continue
+			}
+			complexValue, ok := asMap(element)
+			if !ok {
+				return errors.ScimErrorInvalidValue
+			}
+			if err := removeSubAttribute(complexValue, subAttribute); err != nil {
+				return err
+			}
+			remaining = append(remaining, complexValue)
+		}
+		// If the target location is a multi-valued attribute for which a value selection filter has been supplied and
+		// no record match was made, the operation fails with a "noTarget" error.
+		if !matched {
+			return errors.ScimErrorNoTarget
+		}
+	// If the target location specifies a sub-attribute of a multi-valued attribute, the sub-attribute is removed from
+	// all values.
+	case subAttrName != "" && attr.MultiValued():
+		elements, _ := copyValue(current).([]interface{})
+		for _, element := range elements {
+			if complexValue, ok := asMap(element); ok {
+				if err := removeSubAttribute(complexValue, subAttribute); err != nil {
+					return err
+				}
+			}
+			remaining = append(remaining, element)
+		}
+	case subAttrName != "":
+		complexValue, ok := asMap(copyValue(current))
+		if !ok {
+			return nil
+		}
+		if err := removeSubAttribute(complexValue, subAttribute); err != nil {
+			return err
+		}
+		if len(complexValue) != 0 {
+			container[key] = complexValue
+			return nil
+		}
+	// If the target location is a multi-valued attribute and values are given, only those values are removed.
+	case value != nil && attr.MultiValued():
+		elements, _ := copyValue(current).([]interface{})
+		for _, element := range elements {
+			var matched bool
+			for _, v := range toSlice(value) {
+				if matchesValue(element, v) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				remaining = append(remaining, element)
+			}
+		}
+	}
+
+	// If no values remain after removal, the attribute is considered unassigned.
+	if len(remaining) == 0 {
+		if attr.Required() {
+			return errors.ScimErrorInvalidValue
+		}
+		delete(container, key)
+		return nil
+	}
+	container[key] = remaining
+	return nil
+}
+
+// setSubAttribute sets the given sub-attribute of the given complex value.
+func setSubAttribute(complexValue map[string]interface{}, subAttribute schema.CoreAttribute, value interface{}, replace bool) error {
+	if attributeMutability(subAttribute) == "readOnly" {
+		return errors.ScimErrorMutability
+	}
+	key := findKey(complexValue, subAttribute.Name())
+	current := complexValue[key]
+	if subAttribute.MultiValued() && !replace {
+		elements, _ := current.([]interface{})
+		for _, v := range toSlice(value) {
+			if !containsValue(elements, v) {
+				elements = append(elements, v)
+			}
+		}
+		value = elements
+	}
+	if attributeMutability(subAttribute) == "immutable" && current != nil && !equalValues(current, value) {
+		return errors.ScimErrorMutability
+	}
+	complexValue[key] = value
+	return nil
+}
+
+// removeUnassigned applies a "remove" operation to an attribute without value. It fails with a "noTarget" error if a
+// value selection filter is specified, since no value can match it.
+func removeUnassigned(path filter.Path) error {
+	if path.ValueExpression != nil {
+		return errors.ScimErrorNoTarget
+	}
+	return nil
+}
+
+// removeSubAttribute removes the given sub-attribute from the given complex value. Required sub-attributes can not be
+// removed.
+func removeSubAttribute(complexValue map[string]interface{}, subAttribute schema.CoreAttribute) error {
+	key := findKey(complexValue, subAttribute.Name())
+	if complexValue[key] == nil {
+		return nil
+	}
+	if attributeMutability(subAttribute) == "readOnly" || attributeMutability(subAttribute) == "immutable" {
+		return errors.ScimErrorMutability
+	}
+	if subAttribute.Required() {
+		return errors.ScimErrorInvalidValue
+	}
+	delete(complexValue, key)
+	return nil
+}
+
+// mergeValue returns the value of the given single-valued attribute after adding the given value. The sub-attributes of
+// complex values are merged, all other values are replaced.
+func mergeValue(attr schema.CoreAttribute, current, value interface{}) interface{} {
+	complexValue, ok := asMap(current)
+	if !ok || !attr.HasSubAttributes() {
+		return value
+	}
+	newValue, ok := asMap(value)
+	if !ok {
+		return value
+	}
+	for k, v := range newValue {
+		complexValue[findKey(complexValue, k)] = v
+	}
+	return complexValue
+}
+
+// matchesValueFilter reports whether the given value of the given multi-valued attribute matches the value filter. An
+// "invalidFilter" error is returned if the filter can not be evaluated against the value, e.g., because the value has
+// an unexpected type.
+func matchesValueFilter(ref schema.Schema, attr schema.CoreAttribute, expression filter.Expression, value interface{}) (matches bool, err error) {
+	attributes := f.MultiValuedFilterAttributes(attr)
+	complexValue, ok := asMap(value)
+	if !ok {
+		complexValue = map[string]interface{}{"value": value}
+	}
+
+	// The filter validator expects the names of the sub-attributes as they are defined in the schema.
+	normalized := make(map[string]interface{})
+	for k, v := range complexValue {
+		if v == nil {
+			continue
+		}
+		if subAttribute, ok := attributes.ContainsAttribute(k); ok {
+			normalized[subAttribute.Name()] = filterValue(subAttribute, v)
+		}
+	}
+	validator := f.NewFilterValidator(expression, schema.Schema{
+		ID:         ref.ID,
+		Attributes: attributes,
+	})
+	// The filter validator panics if a value does not have the type of its attribute, which is not validated for the
+	// stored attributes of a resource.
+	defer func() {
+		if r := recover(); r != nil {
+			matches, err = false, errors.ScimErrorInvalidFilter
+		}
+	}()
+	return validator.PassesFilter(normalized) == nil, nil
+}
+
+// filterValue converts the given value of the given attribute to the representation of validated values, which is
+// the representation the filter validator expects. Integers of values that are decoded without
+// json.Decoder.UseNumber are represented as a float64, while validated integers are represented as an int64.
+func filterValue(attr schema.CoreAttribute, value interface{}) interface{} {
+	if n, ok := value.(float64); ok && attr.AttributeType() == "integer" && n == math.Trunc(n) {
+		return int64(n)
+	}
+	return value
+}
+
+// matchesValue reports whether the given value of a multi-valued attribute matches the given (partial) value. Complex
+// values match if all the given sub-attributes are equal.
+func matchesValue(value, partial interface{}) bool {
+	complexValue, ok := asMap(value)
+	if !ok {
+		return equalValues(value, partial)
+	}
+	partialValue, ok := asMap(partial)
+	if !ok {
+		return false
+	}
+	for k, v := range partialValue {
+		if v == nil {
+			continue
+		}
+		if !equalValues(lookupFold(complexValue, k), v) {
+			return false
+		}
+	}
+	return true
+}
+
+// setPrimary ensures that the value at the given index is the only primary value, if it is marked as primary.
+// More info: https://datatracker.ietf.org/doc/html/rfc7643#section-2.4
+func setPrimary(elements []interface{}, index int) {
+	complexValue, ok := asMap(elements[index])
+	if !ok {
+		return
+	}
+	if primary, ok := lookupFold(complexValue, "primary").(bool); !ok || !primary {
+		return
+	}
+	for i, element := range elements {
+		if i == index {
+			continue
+		}
+		if complexValue, ok := asMap(element); ok {
+			key := findKey(complexValue, "primary")
+			if primary, ok := complexValue[key].(bool); ok && primary {
+				complexValue[key] = false
+			}
+		}
+	}
+}
+
+// containsValue reports whether the given values contain the given value.
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equalValues(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyValue returns a deep copy of the given (complex or multi-valued) value.
+func copyValue(value interface{}) interface{} {
+	if complexValue, ok := asMap(value); ok {
+		c := make(map[string]interface{}, len(complexValue))
+		for k, v := range complexValue {
+			c[k] = copyValue(v)
+		}
+		return c
+	}
+	if values, ok := value.([]interface{}); ok && values != nil {
+		c := make([]interface{}, len(values))
+		for i, v := range values {
+			c[i] = copyValue(v)
+		}
+		return c
+	}
+	return value
+}
+
+// equalValues reports whether the given values are deeply equal. Numbers are compared by their value, regardless of
+// their type.
+func equalValues(a, b interface{}) bool {
+	if x, ok := numeric.Float(a); ok {
+		y, ok := numeric.Float(b)
+		return ok && x == y
+	}
+	if x, ok := asMap(a); ok {
+		y, ok := asMap(b)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !equalValues(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	if x, ok := a.([]interface{}); ok {
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalValues(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// findKey returns the key within the given map that matches the given name case-insensitively. The name itself is
+// returned if there is no such key.
+func findKey(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+// subAttributeName returns the name of the sub-attribute that is targeted by the given path, e.g., "givenName" for
+// both "name.givenName" and "emails[type eq \"work\"].value".
+func subAttributeName(path filter.Path) string {
+	if name := path.SubAttributeName(); name != "" {
+		return name
+	}
+	return path.AttributePath.SubAttributeName()
+}
+
+// firstValue returns the first value of the given multi-valued value. Single values are returned as is.
+func firstValue(value interface{}) interface{} {
+	if values, ok := value.([]interface{}); ok {
+		if len(values) == 0 {
+			return nil
+		}
+		return values[0]
+	}
+	return value
+}
+
+// toSlice returns the given value as a multi-valued value.
+func toSlice(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
@@ -0,0 +1,407 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/schema"
+)
+
+const enterpriseUserSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+func newTestPatchResource() ResourceAttributes {
+	return ResourceAttributes{
+		"userName":   "bjensen",
+		"externalId": "bjensen",
+		"name": map[string]interface{}{
+			"givenName":  "Barbara",
+			"familyName": "Jensen",
+		},
+		"emails": []interface{}{
+			map[string]interface{}{"value": "bjensen@example.com", "type": "work", "primary": true},
+			map[string]interface{}{"value": "babs@jensen.org", "type": "home"},
+		},
+		enterpriseUserSchema: map[string]interface{}{
+			"employeeNumber": "701984",
+		},
+	}
+}
+
+// newTestPatchOperations validates the given operations the same way the server does before they get passed to the
+// Patch method of a resource handler.
+func newTestPatchOperations(t *testing.T, resourceType ResourceType, operations string) []PatchOperation {
+	body := `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": ` + operations + `}`
+	patch, scimErr := resourceType.validatePatch(httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body)))
+	if scimErr != nil {
+		t.Fatal(scimErr)
+	}
+	return patch
+}
+
+func newTestPatchResourceType() ResourceType {
+	return ResourceType{
+		Schema: schema.CoreUserSchema(),
+		SchemaExtensions: []SchemaExtension{
+			{Schema: schema.ExtensionEnterpriseUser()},
+		},
+	}
+}
+
+func applyTestPatch(t *testing.T, operations string) (ResourceAttributes, bool, error) {
+	resourceType := newTestPatchResourceType()
+	return ApplyPatch(
+		newTestPatchResource(),
+		newTestPatchOperations(t, resourceType, operations),
+		resourceType.Schema, resourceType.getSchemaExtensions()...,
+	)
+}
+
+func TestApplyPatchAdd(t *testing.T) {
+	patched, changed, err := applyTestPatch(t, `[
+		{"op": "add", "path": "nickName", "value": "Babs"},
+		{"op": "add", "path": "name", "value": {"middleName": "Jane"}},
+		{"op": "add", "path": "emails", "value": [{"value": "bjensen@example.com", "type": "work", "primary": true}]},
+		{"op": "add", "path": "emails", "value": [{"value": "barbara@example.com", "type": "other", "primary": true}]}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, true, changed)
+	assertEqual(t, "Babs", patched["nickName"])
+
+	name := patched["name"].(map[string]interface{})
+	assertLen(t, name, 3)
+	assertEqual(t, "Jane", name["middleName"])
+	assertEqual(t, "Barbara", name["givenName"])
+
+	// The existing value is not added again, the new primary value replaces the old one.
+	emails := patched["emails"].([]interface{})
+	assertLen(t, emails, 3)
+	assertEqual(t, false, emails[0].(map[string]interface{})["primary"])
+	assertEqual(t, true, emails[2].(map[string]interface{})["primary"])
+}
+
+func TestApplyPatchAddNoPath(t *testing.T) {
+	patched, changed, err := applyTestPatch(t, `[{
+		"op": "add",
+		"value": {
+			"nickName": "Babs",
+			"name.middleName": "Jane",
+			"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department": "Tour Operations"
+		}
+	}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, true, changed)
+	assertEqual(t, "Babs", patched["nickName"])
+	assertEqual(t, "Jane", patched["name"].(map[string]interface{})["middleName"])
+	extension := patched[enterpriseUserSchema].(map[string]interface{})
+	assertEqual(t, "Tour Operations", extension["department"])
+	assertEqual(t, "701984", extension["employeeNumber"])
+}
+
+func TestApplyPatchNotChanged(t *testing.T) {
+	original := newTestPatchResource()
+	patched, changed, err := applyTestPatch(t, `[
+		{"op": "add", "path": "userName", "value": "bjensen"},
+		{"op": "add", "path": "emails", "value": [{"value": "babs@jensen.org", "type": "home"}]},
+		{"op": "replace", "path": "name.givenName", "value": "Barbara"},
+		{"op": "remove", "path": "nickName"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, false, changed)
+	if !equalValues(map[string]interface{}(original), map[string]interface{}(patched)) {
+		t.Errorf("expected %v, got %v", original, patched)
+	}
+}
+
+func TestApplyPatchRemove(t *testing.T) {
+	patched, changed, err := applyTestPatch(t, `[
+		{"op": "remove", "path": "name.givenName"},
+		{"op": "remove", "path": "emails[type eq \"work\"]"},
+		{"op": "remove", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, true, changed)
+	assertLen(t, patched["name"], 1)
+	emails := patched["emails"].([]interface{})
+	assertLen(t, emails, 1)
+	assertEqual(t, "home", emails[0].(map[string]interface{})["type"])
+	// The extension is removed as a whole if no attributes remain.
+	assertNil(t, patched[enterpriseUserSchema], "extension")
+}
+
+func TestApplyPatchRemoveMembers(t *testing.T) {
+	resourceType := ResourceType{Schema: schema.CoreGroupSchema()}
+	attributes := ResourceAttributes{
+		"displayName": "Tour Guides",
+		"members": []interface{}{
+			map[string]interface{}{"value": "2819c223", "display": "Babs Jensen"},
+			map[string]interface{}{"value": "902c246b", "display": "Mandy Pepperidge"},
+		},
+	}
+
+	patched, changed, err := ApplyPatch(attributes, newTestPatchOperations(t, resourceType, `[
+		{"op": "remove", "path": "members", "value": [{"value": "2819c223"}]}
+	]`), resourceType.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, true, changed)
+	members := patched["members"].([]interface{})
+	assertLen(t, members, 1)
+	assertEqual(t, "902c246b", members[0].(map[string]interface{})["value"])
+
+	patched, _, err = ApplyPatch(patched, newTestPatchOperations(t, resourceType, `[
+		{"op": "remove", "path": "members[value eq \"902c246b\"]"}
+	]`), resourceType.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The attribute is unassigned if no values remain.
+	if _, ok := patched["members"]; ok {
+		t.Error("members should be removed")
+	}
+	// The given attributes are never modified.
+	assertLen(t, attributes["members"], 2)
+}
+
+func TestApplyPatchReplace(t *testing.T) {
+	patched, changed, err := applyTestPatch(t, `[
+		{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "barbara@example.com"},
+		{"op": "replace", "path": "name", "value": {"givenName": "Babs"}},
+		{"op": "replace", "value": {"userName": "babs"}}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, true, changed)
+	assertEqual(t, "babs", patched["userName"])
+	emails := patched["emails"].([]interface{})
+	assertLen(t, emails, 2)
+	assertEqual(t, "barbara@example.com", emails[0].(map[string]interface{})["value"])
+	// Sub-attributes that are not specified are left unchanged.
+	name := patched["name"].(map[string]interface{})
+	assertEqual(t, "Babs", name["givenName"])
+	assertEqual(t, "Jensen", name["familyName"])
+
+	patched, _, err = applyTestPatch(t, `[
+		{"op": "replace", "path": "emails", "value": [{"value": "babs@example.com"}]}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLen(t, patched["emails"], 1)
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations string
+		err        errors.ScimError
+	}{
+		{
+			name:       "no match",
+			operations: `[{"op": "replace", "path": "emails[type eq \"other\"].value", "value": "babs@example.com"}]`,
+			err:        errors.ScimErrorNoTarget,
+		},
+		{
+			name:       "remove no match",
+			operations: `[{"op": "remove", "path": "emails[type eq \"other\"]"}]`,
+			err:        errors.ScimErrorNoTarget,
+		},
+		{
+			name:       "remove no match sub-attribute",
+			operations: `[{"op": "remove", "path": "emails[type eq \"other\"].display"}]`,
+			err:        errors.ScimErrorNoTarget,
+		},
+		{
+			name:       "remove no match unassigned",
+			operations: `[{"op": "remove", "path": "phoneNumbers[type eq \"work\"]"}]`,
+			err:        errors.ScimErrorNoTarget,
+		},
+		{
+			name:       "remove required",
+			operations: `[{"op": "remove", "path": "userName"}]`,
+			err:        errors.ScimErrorInvalidValue,
+		},
+		{
+			name:       "read only",
+			operations: `[{"op": "add", "path": "groups", "value": [{"value": "e9e30dba"}]}]`,
+			err:        errors.ScimErrorMutability,
+		},
+		{
+			name:       "read only sub-attribute",
+			operations: `[{"op": "add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.displayName", "value": "John Smith"}]`,
+			err:        errors.ScimErrorMutability,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := applyTestPatch(t, test.operations)
+			scimErr, ok := err.(errors.ScimError)
+			assertTypeOk(t, ok, "scim error")
+			assertEqualSCIMErrors(t, &test.err, &scimErr)
+		})
+	}
+}
+
+func TestApplyPatchImmutable(t *testing.T) {
+	resourceType := ResourceType{Schema: schema.CoreGroupSchema()}
+	attributes := ResourceAttributes{
+		"displayName": "Tour Guides",
+		"members": []interface{}{
+			map[string]interface{}{"value": "2819c223", "display": "Babs Jensen"},
+		},
+	}
+
+	_, _, err := ApplyPatch(attributes, newTestPatchOperations(t, resourceType, `[
+		{"op": "replace", "path": "members[value eq \"2819c223\"].display", "value": "Barbara Jensen"}
+	]`), resourceType.Schema)
+	scimErr, ok := err.(errors.ScimError)
+	assertTypeOk(t, ok, "scim error")
+	assertEqualSCIMErrors(t, &errors.ScimErrorMutability, &scimErr)
+
+	// Immutable sub-attributes can be defined if no value exists yet.
+	patched, changed, err := ApplyPatch(attributes, newTestPatchOperations(t, resourceType, `[
+		{"op": "add", "path": "members[value eq \"2819c223\"].type", "value": "User"}
+	]`), resourceType.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, true, changed)
+	assertEqual(t, "User", patched["members"].([]interface{})[0].(map[string]interface{})["type"])
+}
+
+func TestApplyPatchDecodedNumbers(t *testing.T) {
+	resourceType := ResourceType{Schema: schema.Schema{
+		ID: "urn:ietf:params:scim:schemas:core:2.0:Thing",
+		Attributes: []schema.CoreAttribute{
+			schema.ComplexCoreAttribute(schema.ComplexParams{
+				Name:        "things",
+				MultiValued: true,
+				SubAttributes: []schema.SimpleParams{
+					schema.SimpleNumberParams(schema.NumberParams{Name: "n", Type: schema.AttributeTypeInteger()}),
+					schema.SimpleStringParams(schema.StringParams{Name: "v"}),
+				},
+			}),
+		},
+	}}
+	// Numbers of attributes that are decoded without json.Decoder.UseNumber are represented as a float64.
+	var attributes ResourceAttributes
+	if err := json.Unmarshal([]byte(`{"things": [{"n": 1, "v": "one"}, {"n": 2, "v": "two"}]}`), &attributes); err != nil {
+		t.Fatal(err)
+	}
+
+	patched, changed, err := ApplyPatch(attributes, newTestPatchOperations(t, resourceType, `[
+		{"op": "replace", "path": "things[n eq 1].v", "value": "uno"},
+		{"op": "remove", "path": "things[n eq 2]"}
+	]`), resourceType.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, true, changed)
+	things := patched["things"].([]interface{})
+	assertLen(t, things, 1)
+	assertEqual(t, "uno", things[0].(map[string]interface{})["v"])
+
+	// Values that do not have the type of their attribute can not be filtered.
+	attributes = ResourceAttributes{"things": []interface{}{
+		map[string]interface{}{"n": "1", "v": "one"},
+	}}
+	_, _, err = ApplyPatch(attributes, newTestPatchOperations(t, resourceType, `[
+		{"op": "replace", "path": "things[n eq 1].v", "value": "uno"}
+	]`), resourceType.Schema)
+	scimErr, ok := err.(errors.ScimError)
+	assertTypeOk(t, ok, "scim error")
+	assertEqualSCIMErrors(t, &errors.ScimErrorInvalidFilter, &scimErr)
+}
+
+// testReplaceOnlyHandler is a resource handler that can not patch resources natively.
+type testReplaceOnlyHandler struct {
+	testResourceHandler
+	expectedVersions *[]string
+}
+
+func (h testReplaceOnlyHandler) Patch(r *http.Request, id string, operations []PatchOperation) (Resource, error) {
+	return Resource{}, errors.ScimError{Status: http.StatusNotImplemented}
+}
+
+func (h testReplaceOnlyHandler) Replace(r *http.Request, id string, attributes ResourceAttributes) (Resource, error) {
+	version, _ := ExpectedVersion(r)
+	*h.expectedVersions = append(*h.expectedVersions, version)
+	return h.testResourceHandler.Replace(r, id, attributes)
+}
+
+func TestServerResourcePatchHandlerByReplace(t *testing.T) {
+	var expectedVersions []string
+	s := newTestServer()
+	s.ResourceTypes[0].PatchByReplace = true
+	s.ResourceTypes[0].Handler = testReplaceOnlyHandler{
+		testResourceHandler: newTestResourceHandler().(testResourceHandler),
+		expectedVersions:    &expectedVersions,
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/Users/0001", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "replace", "path": "userName", "value": "babs"},
+			{"op": "add", "path": "displayName", "value": "Babs Jensen"}
+		]
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	assertEqualStrings(t, []string{"v1"}, expectedVersions)
+
+	resource, err := s.ResourceTypes[0].Handler.Get(req, "0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "babs", resource.Attributes["userName"])
+	assertEqual(t, "Babs Jensen", resource.Attributes["displayName"])
+	// The external identifier is passed to the handler with the other attributes.
+	assertEqual(t, "external1", resource.ExternalID.Value())
+
+	// The resource is not replaced if the operations do not change it.
+	req = httptest.NewRequest(http.MethodPatch, "/Users/0002", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "path": "userName", "value": "test02"}]
+	}`))
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusNoContent, rr.Code)
+	assertLen(t, expectedVersions, 1)
+
+	// The patched resource is validated like a replaced resource, so required attributes can not be removed.
+	req = httptest.NewRequest(http.MethodPatch, "/Users/0002", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "remove", "path": "userName"}]
+	}`))
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+	assertLen(t, expectedVersions, 1)
+
+	// Values that are invalid for the schema, e.g., a stored value of another type, are not replaced.
+	req = httptest.NewRequest(http.MethodPatch, "/Users/0002", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "add", "path": "displayName", "value": "Test"}]
+	}`))
+	handler := s.ResourceTypes[0].Handler.(testReplaceOnlyHandler)
+	handler.data["0002"].resourceAttributes["active"] = "yes"
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+	assertLen(t, expectedVersions, 1)
+}
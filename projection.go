@@ -0,0 +1,389 @@
+package scim
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// asMap returns the given value as a map if it represents a complex value.
+func asMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case ResourceAttributes:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+func containsFold(arr []string, el string) bool {
+	for _, item := range arr {
+		if strings.EqualFold(item, el) {
+			return true
+		}
+	}
+	return false
+}
+
+// getAttributePaths returns the attribute paths in the comma separated parameter with the given key.
+func getAttributePaths(values url.Values, key string) ([]filter.AttributePath, error) {
+	var paths []filter.AttributePath
+	for _, raw := range strings.Split(values.Get(key), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		path, err := filter.ParseAttrPath([]byte(raw))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// attributeProjection determines which attributes of a resource are returned, based on the "attributes" and
+// "excludedAttributes" parameters and the "returned" characteristic of the attributes.
+// More info: https://datatracker.ietf.org/doc/html/rfc7644#section-3.9
+type attributeProjection struct {
+	// attributes is the list of attributes to return, overriding the default set of attributes.
+	attributes []filter.AttributePath
+	// excludedAttributes is the list of attributes to remove from the default set of attributes.
+	excludedAttributes []filter.AttributePath
+	// requested is the list of attributes that were specified by the client in the body of a POST, PUT or PATCH
+	// request. Attributes that are only returned on request are returned if they are in this list.
+	requested []filter.AttributePath
+	// access limits the returned attributes to the attributes that the client is permitted to read.
+	access attributeAccess
+}
+
+// newAttributeProjection returns the attribute projection that corresponds with the "attributes" and
+// "excludedAttributes" parameters in the given values.
+func newAttributeProjection(values url.Values) (attributeProjection, *errors.ScimError) {
+	attributes, err := getAttributePaths(values, "attributes")
+	if err != nil {
+		scimErr := errors.ScimErrorBadParams([]string{"attributes"})
+		return attributeProjection{}, &scimErr
+	}
+	excludedAttributes, err := getAttributePaths(values, "excludedAttributes")
+	if err != nil {
+		scimErr := errors.ScimErrorBadParams([]string{"excludedAttributes"})
+		return attributeProjection{}, &scimErr
+	}
+	return attributeProjection{
+		attributes:         attributes,
+		excludedAttributes: excludedAttributes,
+	}, nil
+}
+
+// projection returns the attribute projection that corresponds with the list request parameters.
+func (params ListRequestParams) projection() attributeProjection {
+	return attributeProjection{
+		attributes:         params.Attributes,
+		excludedAttributes: params.ExcludedAttributes,
+	}
+}
+
+// apply returns the attributes of the given resource that should be returned.
+func (p attributeProjection) apply(resourceType ResourceType, resource ResourceAttributes) ResourceAttributes {
+	var (
+		main      = resourceType.schemaWithCommon()
+		common    = schema.Attributes(schema.CommonAttributes())
+		projected = make(ResourceAttributes)
+	)
+	for k, v := range resource {
+		// The "schemas" attribute and the "id" attribute are always returned.
+		if strings.EqualFold(k, "schemas") || strings.EqualFold(k, schema.CommonAttributeID) {
+			projected[k] = v
+			continue
+		}
+
+		if extension, ok := resourceType.getSchemaExtension(k); ok {
+			attributes, ok := asMap(v)
+			if !ok {
+				continue
+			}
+			extensionAttributes := make(map[string]interface{})
+			for k, v := range attributes {
+				if value, ok := p.projectAttribute(resourceType, extension, k, v); ok {
+					extensionAttributes[k] = value
+				}
+			}
+			if len(extensionAttributes) != 0 {
+				projected[k] = extensionAttributes
+			}
+			continue
+		}
+
+		ref := main
+		if _, ok := main.Attributes.ContainsAttribute(k); !ok {
+			if _, ok := common.ContainsAttribute(k); ok {
+				ref = schema.Schema{ID: main.ID, Attributes: common}
+			}
+		}
+		if value, ok := p.projectAttribute(resourceType, ref, k, v); ok {
+			projected[k] = value
+		}
+	}
+	return p.access.filterResource(resourceType, projected)
+}
+
+// projectAttribute returns the (projected) value of the attribute with the given name within the given reference
+// schema, and whether the attribute should be returned. Unknown attributes are treated as attributes that are returned
+// by default.
+func (p attributeProjection) projectAttribute(resourceType ResourceType, ref schema.Schema, name string, value interface{}) (interface{}, bool) {
+	var (
+		returned      = "default"
+		subAttributes schema.Attributes
+	)
+	if attr, ok := ref.Attributes.ContainsAttribute(name); ok {
+		if neverReturned(attr) {
+			return nil, false
+		}
+		returned = attributeReturned(attr)
+		subAttributes = attr.SubAttributes()
+	}
+
+	if returned == "always" {
+		return withoutNeverReturned(subAttributes, value), true
+	}
+
+	selected, selectedSubAttributes := p.selects(p.attributes, resourceType, ref.ID, name)
+	if len(p.attributes) != 0 {
+		if !selected && len(selectedSubAttributes) == 0 {
+			return nil, false
+		}
+	} else if returned == "request" {
+		if requested, requestedSubAttributes := p.selects(p.requested, resourceType, ref.ID, name); !requested {
+			if len(requestedSubAttributes) == 0 {
+				return nil, false
+			}
+			selectedSubAttributes = requestedSubAttributes
+		}
+	}
+
+	excluded, excludedSubAttributes := p.selects(p.excludedAttributes, resourceType, ref.ID, name)
+	if excluded {
+		return nil, false
+	}
+
+	if len(subAttributes) == 0 {
+		return value, true
+	}
+	if selected {
+		selectedSubAttributes = nil
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		if v == nil {
+			return v, true
+		}
+		values := make([]interface{}, len(v))
+		for i, v := range v {
+			values[i] = projectSubAttributes(subAttributes, v, selectedSubAttributes, excludedSubAttributes)
+		}
+		return values, true
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, v := range v {
+			values[i] = projectSubAttributes(subAttributes, v, selectedSubAttributes, excludedSubAttributes)
+		}
+		return values, true
+	default:
+		return projectSubAttributes(subAttributes, v, selectedSubAttributes, excludedSubAttributes), true
+	}
+}
+
+// selects reports whether the given paths select the attribute with the given name of the schema with the given id as
+// a whole. If not, it returns the names of the sub-attributes of that attribute that are selected.
+func (p attributeProjection) selects(paths []filter.AttributePath, resourceType ResourceType, schemaID, name string) (bool, []string) {
+	var subAttributes []string
+	for _, path := range paths {
+		uri := path.URI()
+		if uri == "" {
+			// Attributes of schema extensions can only be referenced without their schema URI if the name is not
+			// ambiguous with an attribute of the main schema.
+			if schemaID != resourceType.Schema.ID {
+				if _, ok := resourceType.Schema.Attributes.ContainsAttribute(path.AttributeName); ok {
+					continue
+				}
+			}
+		} else if !strings.EqualFold(uri, schemaID) {
+			// e.g. "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User" selects the extension as a whole.
+			if path.SubAttribute == nil && strings.EqualFold(uri+":"+path.AttributeName, schemaID) {
+				return true, nil
+			}
+			continue
+		}
+
+		if !strings.EqualFold(path.AttributeName, name) {
+			continue
+		}
+		if subAttrName := path.SubAttributeName(); subAttrName != "" {
+			subAttributes = append(subAttributes, subAttrName)
+			continue
+		}
+		return true, nil
+	}
+	return false, subAttributes
+}
+
+// projectSubAttributes returns the sub-attributes of the given complex value that should be returned. If selected is
+// not empty, only those sub-attributes are returned. Sub-attributes in excluded are never returned.
+func projectSubAttributes(subAttributes schema.Attributes, value interface{}, selected, excluded []string) interface{} {
+	complexValue, ok := asMap(value)
+	if !ok {
+		return value
+	}
+
+	projected := make(map[string]interface{})
+	for k, v := range complexValue {
+		returned := "default"
+		if attr, ok := subAttributes.ContainsAttribute(k); ok {
+			if neverReturned(attr) {
+				continue
+			}
+			returned = attributeReturned(attr)
+			v = withoutNeverReturned(attr.SubAttributes(), v)
+		}
+
+		switch returned {
+		case "always":
+			projected[k] = v
+			continue
+		case "request":
+			if !containsFold(selected, k) {
+				continue
+			}
+		}
+		if len(selected) != 0 && !containsFold(selected, k) {
+			continue
+		}
+		if containsFold(excluded, k) {
+			continue
+		}
+		projected[k] = v
+	}
+	return projected
+}
+
+// neverReturned reports whether the given attribute is never returned, i.e. it has a "returned" characteristic of
+// "never" or a "mutability" of "writeOnly".
+func neverReturned(attr schema.CoreAttribute) bool {
+	return attributeReturned(attr) == "never" || attributeMutability(attr) == "writeOnly"
+}
+
+// withoutNeverReturned returns the given (complex) value without the sub-attributes that are never returned, at any
+// nesting level. The given value is not modified.
+func withoutNeverReturned(subAttributes schema.Attributes, value interface{}) interface{} {
+	if len(subAttributes) == 0 {
+		return value
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		values := make([]interface{}, len(v))
+		for i, v := range v {
+			values[i] = withoutNeverReturned(subAttributes, v)
+		}
+		return values
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, v := range v {
+			values[i] = withoutNeverReturned(subAttributes, v)
+		}
+		return values
+	}
+
+	complexValue, ok := asMap(value)
+	if !ok {
+		return value
+	}
+	filtered := make(map[string]interface{}, len(complexValue))
+	for k, v := range complexValue {
+		if attr, ok := subAttributes.ContainsAttribute(k); ok {
+			if neverReturned(attr) {
+				continue
+			}
+			v = withoutNeverReturned(attr.SubAttributes(), v)
+		}
+		filtered[k] = v
+	}
+	return filtered
+}
+
+// patchedAttributePaths returns the attribute paths of all the attributes that are targeted by the given operations.
+func patchedAttributePaths(resourceType ResourceType, operations []PatchOperation) []filter.AttributePath {
+	var paths []filter.AttributePath
+	for _, op := range operations {
+		if op.Path != nil {
+			paths = append(paths, op.Path.AttributePath)
+			continue
+		}
+		attributes, ok := asMap(op.Value)
+		if !ok {
+			continue
+		}
+		for k, v := range attributes {
+			if extension, ok := resourceType.getSchemaExtension(k); ok {
+				paths = append(paths, extensionAttributePaths(extension, v)...)
+				continue
+			}
+			if path, err := filter.ParseAttrPath([]byte(k)); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// requestedAttributePaths returns the attribute paths of all the given attributes, including the ones of the schema
+// extensions.
+func requestedAttributePaths(resourceType ResourceType, attributes ResourceAttributes) []filter.AttributePath {
+	var paths []filter.AttributePath
+	for k, v := range attributes {
+		if extension, ok := resourceType.getSchemaExtension(k); ok {
+			paths = append(paths, extensionAttributePaths(extension, v)...)
+			continue
+		}
+		paths = append(paths, filter.AttributePath{AttributeName: k})
+	}
+	return paths
+}
+
+// extensionAttributePaths returns the (fully qualified) attribute paths of the attributes in the given extension value.
+func extensionAttributePaths(extension schema.Schema, value interface{}) []filter.AttributePath {
+	attributes, ok := asMap(value)
+	if !ok {
+		return nil
+	}
+	var paths []filter.AttributePath
+	for k := range attributes {
+		uri := extension.ID
+		paths = append(paths, filter.AttributePath{
+			URIPrefix:     &uri,
+			AttributeName: k,
+		})
+	}
+	return paths
+}
+
+// attributeMutability returns the mutability of the given attribute, e.g., "readOnly". Unlike
+// schema.CoreAttribute.Mutability, the value is not JSON encoded.
+func attributeMutability(attr schema.CoreAttribute) string {
+	return strings.Trim(attr.Mutability(), `"`)
+}
+
+// attributeReturned returns when the given attribute is returned, e.g., "never". Unlike schema.CoreAttribute.Returned,
+// the value is not JSON encoded.
+func attributeReturned(attr schema.CoreAttribute) string {
+	return strings.Trim(attr.Returned(), `"`)
+}
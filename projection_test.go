@@ -0,0 +1,276 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/elimity-com/scim/schema"
+)
+
+func newTestProjectionResourceType() ResourceType {
+	return ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema: schema.Schema{
+			ID: "urn:ietf:params:scim:schemas:core:2.0:User",
+			Attributes: []schema.CoreAttribute{
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name:     "userName",
+					Returned: schema.AttributeReturnedAlways(),
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name: "displayName",
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name:     "password",
+					Returned: schema.AttributeReturnedNever(),
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name:     "nickName",
+					Returned: schema.AttributeReturnedRequest(),
+				})),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					Name: "name",
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{Name: "givenName"}),
+						schema.SimpleStringParams(schema.StringParams{Name: "familyName"}),
+					},
+				}),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					MultiValued: true,
+					Name:        "emails",
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{Name: "value"}),
+						schema.SimpleStringParams(schema.StringParams{Name: "type"}),
+					},
+				}),
+			},
+		},
+		SchemaExtensions: []SchemaExtension{
+			{Schema: schema.ExtensionEnterpriseUser()},
+		},
+	}
+}
+
+func newTestProjectionResource() ResourceAttributes {
+	return ResourceAttributes{
+		"schemas":     []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"id":          "0001",
+		"userName":    "bjensen",
+		"displayName": "Babs Jensen",
+		"password":    "secret",
+		"nickName":    "Babs",
+		"name": map[string]interface{}{
+			"givenName":  "Barbara",
+			"familyName": "Jensen",
+		},
+		"emails": []interface{}{
+			map[string]interface{}{"value": "bjensen@example.com", "type": "work"},
+		},
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]interface{}{
+			"employeeNumber": "701984",
+			"costCenter":     "4130",
+		},
+	}
+}
+
+func TestAttributeProjection(t *testing.T) {
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{
+			name:     "default",
+			query:    "",
+			expected: []string{"schemas", "id", "userName", "displayName", "name", "emails", enterprise},
+		}, {
+			name:     "attributes",
+			query:    "attributes=displayName",
+			expected: []string{"schemas", "id", "userName", "displayName"},
+		}, {
+			name:     "attributes on request",
+			query:    "attributes=nickName,password",
+			expected: []string{"schemas", "id", "userName", "nickName"},
+		}, {
+			name:     "excluded attributes",
+			query:    "excludedAttributes=displayName,emails,userName",
+			expected: []string{"schemas", "id", "userName", "name", enterprise},
+		}, {
+			name:     "extension attributes",
+			query:    "attributes=" + enterprise + ":employeeNumber",
+			expected: []string{"schemas", "id", "userName", enterprise},
+		}, {
+			name:     "excluded extension",
+			query:    "excludedAttributes=" + enterprise,
+			expected: []string{"schemas", "id", "userName", "displayName", "name", "emails"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(test.query)
+			projection, scimErr := newAttributeProjection(values)
+			if scimErr != nil {
+				t.Fatal(scimErr)
+			}
+
+			projected := projection.apply(newTestProjectionResourceType(), newTestProjectionResource())
+			assertLen(t, projected, len(test.expected))
+			for _, k := range test.expected {
+				assertNotNil(t, projected[k], k)
+			}
+		})
+	}
+}
+
+func TestAttributeProjectionRequested(t *testing.T) {
+	resourceType := newTestProjectionResourceType()
+	projection := attributeProjection{
+		requested: requestedAttributePaths(resourceType, ResourceAttributes{
+			"nickName": "Babs",
+		}),
+	}
+	projected := projection.apply(resourceType, newTestProjectionResource())
+	assertEqual(t, "Babs", projected["nickName"])
+	assertNil(t, projected["password"], "password")
+}
+
+func TestAttributeProjectionSubAttributes(t *testing.T) {
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	values, _ := url.ParseQuery("attributes=name.givenName,emails.value," + enterprise + ":employeeNumber")
+	projection, _ := newAttributeProjection(values)
+	projected := projection.apply(newTestProjectionResourceType(), newTestProjectionResource())
+
+	name, ok := projected["name"].(map[string]interface{})
+	assertTypeOk(t, ok, "object")
+	assertLen(t, name, 1)
+	assertEqual(t, "Barbara", name["givenName"])
+
+	emails, ok := projected["emails"].([]interface{})
+	assertTypeOk(t, ok, "array")
+	email, ok := emails[0].(map[string]interface{})
+	assertTypeOk(t, ok, "object")
+	assertLen(t, email, 1)
+	assertEqual(t, "bjensen@example.com", email["value"])
+
+	extension, ok := projected[enterprise].(map[string]interface{})
+	assertTypeOk(t, ok, "object")
+	assertLen(t, extension, 1)
+	assertEqual(t, "701984", extension["employeeNumber"])
+}
+
+func TestAttributeProjectionNeverReturned(t *testing.T) {
+	const extension = "urn:example:params:scim:schemas:extension:2.0:Secrets"
+	resourceType := ResourceType{
+		Schema: schema.Schema{
+			ID: "urn:example:params:scim:schemas:core:2.0:Device",
+			Attributes: []schema.CoreAttribute{
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name:       "pin",
+					Mutability: schema.AttributeMutabilityWriteOnly(),
+				})),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					Name:     "owner",
+					Returned: schema.AttributeReturnedAlways(),
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{Name: "value"}),
+						schema.SimpleStringParams(schema.StringParams{
+							Name:     "secret",
+							Returned: schema.AttributeReturnedNever(),
+						}),
+					},
+				}),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					MultiValued: true,
+					Name:        "keys",
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{Name: "value"}),
+						schema.SimpleStringParams(schema.StringParams{
+							Name:       "secret",
+							Mutability: schema.AttributeMutabilityWriteOnly(),
+						}),
+					},
+				}),
+			},
+		},
+		SchemaExtensions: []SchemaExtension{{
+			Schema: schema.Schema{
+				ID: extension,
+				Attributes: []schema.CoreAttribute{
+					schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+						Name:       "token",
+						Mutability: schema.AttributeMutabilityWriteOnly(),
+					})),
+					schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+						Name: "hint",
+					})),
+				},
+			},
+		}},
+	}
+	resource := ResourceAttributes{
+		"id":    "0001",
+		"pin":   "1234",
+		"owner": map[string]interface{}{"value": "2819c223", "secret": "s3cr3t"},
+		"keys": []map[string]interface{}{
+			{"value": "primary", "secret": "s3cr3t"},
+		},
+		extension: map[string]interface{}{"token": "s3cr3t", "hint": "pet"},
+	}
+
+	projected := attributeProjection{}.apply(resourceType, resource)
+	assertNil(t, projected["pin"], "pin")
+	assertEqual(t, "2819c223", projected["owner"].(map[string]interface{})["value"])
+	assertNil(t, projected["owner"].(map[string]interface{})["secret"], "owner.secret")
+	key := projected["keys"].([]interface{})[0].(map[string]interface{})
+	assertLen(t, key, 1)
+	assertEqual(t, "primary", key["value"])
+	secrets := projected[extension].(map[string]interface{})
+	assertLen(t, secrets, 1)
+	assertEqual(t, "pet", secrets["hint"])
+
+	// Attributes that are never returned can not be requested either.
+	values, _ := url.ParseQuery("attributes=pin,keys.secret," + extension + ":token")
+	projection, _ := newAttributeProjection(values)
+	projected = projection.apply(resourceType, resource)
+	assertNil(t, projected["pin"], "pin")
+	assertNil(t, projected[extension], "extension")
+	assertLen(t, projected["keys"].([]interface{})[0], 0)
+}
+
+func TestServerResourceGetHandlerAttributes(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/Users/0001?attributes=externalId", nil)
+	rr := httptest.NewRecorder()
+	newTestServer().ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var resource map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+	assertEqual(t, "external1", resource["externalId"])
+	assertEqual(t, "0001", resource["id"])
+	assertNil(t, resource["userName"], "userName")
+	assertNil(t, resource["meta"], "meta")
+}
+
+func TestServerResourcesGetHandlerExcludedAttributes(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/Users?count=1&excludedAttributes=userName,meta", nil)
+	rr := httptest.NewRecorder()
+	newTestServer().ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response listResponse
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertLen(t, response.Resources, 1)
+	resource, ok := response.Resources[0].(map[string]interface{})
+	assertTypeOk(t, ok, "object")
+	assertNotNil(t, resource["externalId"], "externalId")
+	assertNil(t, resource["userName"], "userName")
+	assertNil(t, resource["meta"], "meta")
+}
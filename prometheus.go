@@ -0,0 +1,174 @@
+package scim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultDurationBuckets are the upper bounds (in seconds) of the buckets of the duration histograms.
+var defaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusObserver is an observer that collects request and phase metrics, and exposes them in the Prometheus text
+// exposition format. It is an http.Handler, so it can be served on a metrics endpoint, e.g., "/metrics". The
+// following metrics are collected:
+//   - scim_requests_total: the number of requests by method, endpoint, status and SCIM error type.
+//   - scim_request_duration_seconds: a histogram of the request durations by method and endpoint.
+//   - scim_phase_duration_seconds: a histogram of the phase durations by phase, resource type and handler method.
+//   - scim_phase_errors_total: the number of failed phases by phase, resource type and handler method.
+//
+// More info: https://prometheus.io/docs/instrumenting/exposition_formats
+type PrometheusObserver struct {
+	mu               sync.Mutex
+	requests         map[string]float64
+	requestDurations map[string]*histogram
+	phaseDurations   map[string]*histogram
+	phaseErrors      map[string]float64
+}
+
+// NewPrometheusObserver returns a new observer without metrics.
+func NewPrometheusObserver() *PrometheusObserver {
+	return &PrometheusObserver{
+		requests:         make(map[string]float64),
+		requestDurations: make(map[string]*histogram),
+		phaseDurations:   make(map[string]*histogram),
+		phaseErrors:      make(map[string]float64),
+	}
+}
+
+// PhaseEnd records the duration of the phase, and whether it failed.
+func (o *PrometheusObserver) PhaseEnd(_ context.Context, phase PhaseObservation) {
+	labels := metricLabels(
+		"phase", string(phase.Phase),
+		"resource_type", phase.ResourceType,
+		"handler_method", phase.HandlerMethod,
+	)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	observe(o.phaseDurations, labels, phase.Duration.Seconds())
+	if phase.Err != nil {
+		o.phaseErrors[labels]++
+	}
+}
+
+// PhaseStart does nothing, the phases are recorded when they end.
+func (o *PrometheusObserver) PhaseStart(ctx context.Context, _ PhaseObservation) context.Context {
+	return ctx
+}
+
+// RequestEnd records the request and its duration.
+func (o *PrometheusObserver) RequestEnd(_ context.Context, request RequestObservation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests[metricLabels(
+		"method", request.Method,
+		"endpoint", request.Endpoint,
+		"status", strconv.Itoa(request.Status),
+		"scim_type", request.ScimType,
+	)]++
+	observe(o.requestDurations, metricLabels(
+		"method", request.Method,
+		"endpoint", request.Endpoint,
+	), request.Duration.Seconds())
+}
+
+// RequestStart does nothing, the requests are recorded when they end.
+func (o *PrometheusObserver) RequestStart(ctx context.Context, _ RequestObservation) context.Context {
+	return ctx
+}
+
+// ServeHTTP writes the collected metrics in the Prometheus text exposition format.
+func (o *PrometheusObserver) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = o.WriteMetrics(w)
+}
+
+// WriteMetrics writes the collected metrics in the Prometheus text exposition format to the given writer.
+func (o *PrometheusObserver) WriteMetrics(w io.Writer) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var b strings.Builder
+	writeCounter(&b, "scim_requests_total", "The number of handled SCIM requests.", o.requests)
+	writeHistogram(&b, "scim_request_duration_seconds", "The duration of the handled SCIM requests.", o.requestDurations)
+	writeHistogram(&b, "scim_phase_duration_seconds", "The duration of the phases of the SCIM requests.", o.phaseDurations)
+	writeCounter(&b, "scim_phase_errors_total", "The number of failed phases of the SCIM requests.", o.phaseErrors)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// histogram counts observations in cumulative buckets.
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// observe adds the given value to the histogram with the given labels.
+func observe(histograms map[string]*histogram, labels string, value float64) {
+	h, ok := histograms[labels]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(defaultDurationBuckets))}
+		histograms[labels] = h
+	}
+	for i, bound := range defaultDurationBuckets {
+		if value <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// metricLabels formats the given alternating label names and values, e.g., `method="GET",status="200"`.
+func metricLabels(namesAndValues ...string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(namesAndValues)/2)
+	for i := 0; i+1 < len(namesAndValues); i += 2 {
+		pairs = append(pairs, namesAndValues[i]+`="`+replacer.Replace(namesAndValues[i+1])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// writeCounter writes the counter with the given name and values by labels.
+func writeCounter(b *strings.Builder, name, help string, values map[string]float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]string, 0, len(values))
+	for labels := range values {
+		keys = append(keys, labels)
+	}
+	sort.Strings(keys)
+	for _, labels := range keys {
+		fmt.Fprintf(b, "%s{%s} %s\n", name, labels, formatFloat(values[labels]))
+	}
+}
+
+// writeHistogram writes the histogram with the given name and histograms by labels.
+func writeHistogram(b *strings.Builder, name, help string, histograms map[string]*histogram) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([]string, 0, len(histograms))
+	for labels := range histograms {
+		keys = append(keys, labels)
+	}
+	sort.Strings(keys)
+	for _, labels := range keys {
+		h := histograms[labels]
+		for i, bound := range defaultDurationBuckets {
+			fmt.Fprintf(b, "%s_bucket{%s,le=%q} %d\n", name, labels, formatFloat(bound), h.buckets[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+// formatFloat formats the given value as a Prometheus sample value.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -11,14 +11,31 @@ import (
 
 // ListRequestParams request parameters sent to the API via a "GetAll" route.
 type ListRequestParams struct {
+	// Attributes is a list of attribute paths that overrides the default set of attributes to return.
+	// It is an optional parameter and thus will be nil when the parameter is not present. The projection of the
+	// returned resources is done by the server, this list can be used to only retrieve the necessary attributes.
+	Attributes []filter.AttributePath
+
 	// Count specifies the desired maximum number of query results per page. A negative value SHALL be interpreted as "0".
 	// A value of "0" indicates that no resource results are to be returned except for "totalResults".
 	Count int
 
+	// ExcludedAttributes is a list of attribute paths that are removed from the default set of attributes to return.
+	// It is an optional parameter and thus will be nil when the parameter is not present.
+	ExcludedAttributes []filter.AttributePath
+
 	// Filter represents the parsed and tokenized filter query parameter.
 	// It is an optional parameter and thus will be nil when the parameter is not present.
 	Filter filter.Expression
 
+	// SortBy specifies the attribute whose value is used to order the returned resources.
+	// It is an optional parameter and thus will be nil when the parameter is not present.
+	SortBy *filter.AttributePath
+
+	// SortOrder is the order in which the "sortBy" parameter is applied. It defaults to "ascending" if the "sortBy"
+	// parameter is present and is empty otherwise.
+	SortOrder SortOrder
+
 	// StartIndex The 1-based index of the first query result. A value less than 1 SHALL be interpreted as 1.
 	StartIndex int
 }
@@ -45,7 +62,7 @@ type Resource struct {
 	Meta Meta
 }
 
-func (r Resource) response(resourceType ResourceType) ResourceAttributes {
+func (r Resource) response(resourceType ResourceType, baseURL string) ResourceAttributes {
 	response := r.Attributes
 	if response == nil {
 		response = ResourceAttributes{}
@@ -64,6 +81,7 @@ func (r Resource) response(resourceType ResourceType) ResourceAttributes {
 
 	m := meta{
 		ResourceType: resourceType.Name,
+		Location:     resourceType.location(baseURL, r.ID),
 	}
 
 	if r.Meta.Created != nil {
@@ -109,5 +127,6 @@ type ResourceHandler interface {
 	// 1. the Add/Replace operation should return No Content only when the value already exists AND is the same.
 	// 2. the Remove operation should return No Content when the value to be remove is already absent.
 	// More information in Section 3.5.2 of RFC 7644: https://tools.ietf.org/html/rfc7644#section-3.5.2
+	// ApplyPatch can be used to apply the operations to the stored attributes of the resource.
 	Patch(r *http.Request, id string, operations []PatchOperation) (Resource, error)
 }
@@ -55,11 +55,16 @@ func (h testResourceHandler) Create(r *http.Request, attributes ResourceAttribut
 
 func (h testResourceHandler) Delete(r *http.Request, id string) error {
 	// check if resource exists
-	_, ok := h.data[id]
+	data, ok := h.data[id]
 	if !ok {
 		return errors.ScimErrorResourceNotFound(id)
 	}
 
+	// check if resource has not changed since the client retrieved it
+	if version, ok := ExpectedVersion(r); ok && version != data.meta["version"] {
+		return errors.ScimErrorPreconditionFailed(id)
+	}
+
 	// delete resource
 	delete(h.data, id)
 
@@ -173,11 +178,16 @@ func (h testResourceHandler) Patch(r *http.Request, id string, operations []Patc
 
 func (h testResourceHandler) Replace(r *http.Request, id string, attributes ResourceAttributes) (Resource, error) {
 	// check if resource exists
-	_, ok := h.data[id]
+	data, ok := h.data[id]
 	if !ok {
 		return Resource{}, errors.ScimErrorResourceNotFound(id)
 	}
 
+	// check if resource has not changed since the client retrieved it
+	if version, ok := ExpectedVersion(r); ok && version != data.meta["version"] {
+		return Resource{}, errors.ScimErrorPreconditionFailed(id)
+	}
+
 	// replace (all) attributes
 	h.data[id] = testData{
 		resourceAttributes: attributes,
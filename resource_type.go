@@ -3,7 +3,10 @@ package scim
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/elimity-com/scim/errors"
 	"github.com/elimity-com/scim/internal/patch"
@@ -33,12 +36,84 @@ type ResourceType struct {
 	Schema schema.Schema
 	// SchemaExtensions is a list of the resource type's schema extensions.
 	SchemaExtensions []SchemaExtension
+	// SortPages indicates whether the server sorts the resources of the pages returned by the handler, based on the
+	// "sortBy" and "sortOrder" parameters. Only the resources within a single page get sorted, so the handler is still
+	// responsible for returning the right resources if pagination is used. See SortResources.
+	SortPages bool
+	// PatchByReplace indicates whether the server handles PATCH requests itself, for handlers that can only replace
+	// resources as a whole. The resource is retrieved with Handler.Get, the operations are applied with ApplyPatch and
+	// the result is stored with Handler.Replace, in which case Handler.Patch is never called. The version of the
+	// retrieved resource is passed to Handler.Replace as the expected version, see ExpectedVersion.
+	PatchByReplace bool
 
 	// Handler is the set of callback method that connect the SCIM server with a provider of the resource type.
 	Handler ResourceHandler
 }
 
-func (t ResourceType) getRaw() map[string]interface{} {
+// ParseResourceType converts the json representation of a resource type, as defined in RFC 7643 section 6, to a
+// resource type. It accepts the documents that are returned by the "/ResourceTypes" endpoint. The schema and the schema
+// extensions are referenced by their id, and are looked up in the given schemas. The handler and the other server
+// options of the resource type are not part of the representation, and need to be set afterwards.
+func ParseResourceType(data []byte, schemas ...schema.Schema) (ResourceType, error) {
+	var raw struct {
+		ID               string `json:"id"`
+		Name             string `json:"name"`
+		Description      string `json:"description"`
+		Endpoint         string `json:"endpoint"`
+		Schema           string `json:"schema"`
+		SchemaExtensions []struct {
+			Schema   string `json:"schema"`
+			Required bool   `json:"required"`
+		} `json:"schemaExtensions"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return ResourceType{}, err
+	}
+	if raw.Name == "" {
+		return ResourceType{}, fmt.Errorf("resource type without name")
+	}
+	if raw.Endpoint == "" {
+		return ResourceType{}, fmt.Errorf("resource type %q without endpoint", raw.Name)
+	}
+
+	lookup := func(id string) (schema.Schema, error) {
+		for _, s := range schemas {
+			if s.ID == id {
+				return s, nil
+			}
+		}
+		return schema.Schema{}, fmt.Errorf("unknown schema %q of resource type %q", id, raw.Name)
+	}
+
+	t := ResourceType{
+		Name:     raw.Name,
+		Endpoint: raw.Endpoint,
+	}
+	if raw.ID != "" {
+		t.ID = optional.NewString(raw.ID)
+	}
+	if raw.Description != "" {
+		t.Description = optional.NewString(raw.Description)
+	}
+
+	var err error
+	if t.Schema, err = lookup(raw.Schema); err != nil {
+		return ResourceType{}, err
+	}
+	for _, e := range raw.SchemaExtensions {
+		extension, err := lookup(e.Schema)
+		if err != nil {
+			return ResourceType{}, err
+		}
+		t.SchemaExtensions = append(t.SchemaExtensions, SchemaExtension{
+			Schema:   extension,
+			Required: e.Required,
+		})
+	}
+	return t, nil
+}
+
+func (t ResourceType) getRaw(baseURL string) map[string]interface{} {
 	return map[string]interface{}{
 		"schemas":          []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
 		"id":               t.ID.Value(),
@@ -47,6 +122,10 @@ func (t ResourceType) getRaw() map[string]interface{} {
 		"endpoint":         t.Endpoint,
 		"schema":           t.Schema.ID,
 		"schemaExtensions": t.getRawSchemaExtensions(),
+		"meta": meta{
+			ResourceType: "ResourceType",
+			Location:     joinLocation(baseURL, "ResourceTypes/"+url.PathEscape(t.Name)),
+		},
 	}
 }
 
@@ -61,6 +140,16 @@ func (t ResourceType) getRawSchemaExtensions() []map[string]interface{} {
 	return schemas
 }
 
+// getSchemaExtension returns the schema extension with the given id.
+func (t ResourceType) getSchemaExtension(id string) (schema.Schema, bool) {
+	for _, e := range t.SchemaExtensions {
+		if strings.EqualFold(e.Schema.ID, id) {
+			return e.Schema, true
+		}
+	}
+	return schema.Schema{}, false
+}
+
 func (t ResourceType) getSchemaExtensions() []schema.Schema {
 	var extensions []schema.Schema
 	for _, e := range t.SchemaExtensions {
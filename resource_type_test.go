@@ -0,0 +1,56 @@
+package scim
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestParseResourceType(t *testing.T) {
+	s := newTestServer()
+	var schemas []schema.Schema
+	for _, resourceType := range s.ResourceTypes {
+		schemas = append(schemas, resourceType.Schema)
+		schemas = append(schemas, resourceType.getSchemaExtensions()...)
+	}
+
+	for _, expected := range s.ResourceTypes {
+		t.Run(expected.Name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ResourceTypes/"+expected.Name, nil))
+			assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+			raw, err := ioutil.ReadAll(rr.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := ParseResourceType(raw, schemas...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected.Handler = nil
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("resource type did not survive a round trip. want %v, got %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseResourceTypeInvalid(t *testing.T) {
+	for _, test := range []string{
+		`{`,
+		`{"endpoint": "/Users", "schema": "urn:ietf:params:scim:schemas:core:2.0:User"}`,
+		`{"name": "User", "schema": "urn:ietf:params:scim:schemas:core:2.0:User"}`,
+		`{"name": "User", "endpoint": "/Users", "schema": "urn:ietf:params:scim:schemas:core:2.0:Unknown"}`,
+		`{"name": "User", "endpoint": "/Users", "schema": "urn:ietf:params:scim:schemas:core:2.0:User", "schemaExtensions": [{"schema": "urn:unknown"}]}`,
+	} {
+		if _, err := ParseResourceType([]byte(test), schema.CoreUserSchema()); err == nil {
+			t.Errorf("expected an error for %s", test)
+		}
+	}
+}
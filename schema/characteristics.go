@@ -7,15 +7,18 @@ import (
 )
 
 func checkAttributeName(name string) {
+	if !isValidAttributeName(name) {
+		panic(fmt.Sprintf("invalid attribute name %q", name))
+	}
+}
+
+func isValidAttributeName(name string) bool {
 	// starts w/ a A-Za-z followed by a A-Za-z0-9, a dollar sign, a hyphen or an underscore
 	match, err := regexp.MatchString(`^[A-Za-z][\w$-]*$`, name)
 	if err != nil {
 		panic(err)
 	}
-
-	if !match {
-		panic(fmt.Sprintf("invalid attribute name %q", name))
-	}
+	return match
 }
 
 // AttributeDataType is a single keyword indicating the derived data type from JSON.
@@ -135,6 +138,21 @@ const (
 	attributeMutabilityWriteOnly
 )
 
+func parseAttributeMutability(s string) (attributeMutability, error) {
+	switch s {
+	case "", "readWrite":
+		return attributeMutabilityReadWrite, nil
+	case "immutable":
+		return attributeMutabilityImmutable, nil
+	case "readOnly":
+		return attributeMutabilityReadOnly, nil
+	case "writeOnly":
+		return attributeMutabilityWriteOnly, nil
+	default:
+		return 0, fmt.Errorf("invalid mutability %q", s)
+	}
+}
+
 func (a attributeMutability) MarshalJSON() ([]byte, error) {
 	switch a {
 	case attributeMutabilityImmutable:
@@ -157,6 +175,21 @@ const (
 	attributeReturnedRequest
 )
 
+func parseAttributeReturned(s string) (attributeReturned, error) {
+	switch s {
+	case "", "default":
+		return attributeReturnedDefault, nil
+	case "always":
+		return attributeReturnedAlways, nil
+	case "never":
+		return attributeReturnedNever, nil
+	case "request":
+		return attributeReturnedRequest, nil
+	default:
+		return 0, fmt.Errorf("invalid returned %q", s)
+	}
+}
+
 func (a attributeReturned) MarshalJSON() ([]byte, error) {
 	switch a {
 	case attributeReturnedAlways:
@@ -184,6 +217,29 @@ const (
 	attributeDataTypeString
 )
 
+func parseAttributeType(s string) (attributeType, error) {
+	switch s {
+	case "decimal":
+		return attributeDataTypeDecimal, nil
+	case "integer":
+		return attributeDataTypeInteger, nil
+	case "binary":
+		return attributeDataTypeBinary, nil
+	case "boolean":
+		return attributeDataTypeBoolean, nil
+	case "complex":
+		return attributeDataTypeComplex, nil
+	case "dateTime":
+		return attributeDataTypeDateTime, nil
+	case "reference":
+		return attributeDataTypeReference, nil
+	case "", "string":
+		return attributeDataTypeString, nil
+	default:
+		return 0, fmt.Errorf("invalid type %q", s)
+	}
+}
+
 func (a attributeType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(a.String())
 }
@@ -217,6 +273,19 @@ const (
 	attributeUniquenessServer
 )
 
+func parseAttributeUniqueness(s string) (attributeUniqueness, error) {
+	switch s {
+	case "", "none":
+		return attributeUniquenessNone, nil
+	case "global":
+		return attributeUniquenessGlobal, nil
+	case "server":
+		return attributeUniquenessServer, nil
+	default:
+		return 0, fmt.Errorf("invalid uniqueness %q", s)
+	}
+}
+
 func (a attributeUniqueness) MarshalJSON() ([]byte, error) {
 	switch a {
 	case attributeUniquenessGlobal:
@@ -366,3 +366,78 @@ func (a CoreAttribute) validate(attribute interface{}) (interface{}, *errors.Sci
 		return nil, &errors.ScimErrorInvalidSyntax
 	}
 }
+
+// rawAttribute is the JSON representation of an attribute definition, as defined in RFC 7643 section 7. Absent
+// characteristics get their default values, as defined in RFC 7643 section 2.2.
+type rawAttribute struct {
+	CanonicalValues []string                 `json:"canonicalValues"`
+	CaseExact       bool                     `json:"caseExact"`
+	Description     string                   `json:"description"`
+	MultiValued     bool                     `json:"multiValued"`
+	Mutability      string                   `json:"mutability"`
+	Name            string                   `json:"name"`
+	ReferenceTypes  []AttributeReferenceType `json:"referenceTypes"`
+	Required        bool                     `json:"required"`
+	Returned        string                   `json:"returned"`
+	SubAttributes   []rawAttribute           `json:"subAttributes"`
+	Type            string                   `json:"type"`
+	Uniqueness      string                   `json:"uniqueness"`
+}
+
+// parse converts the raw attribute to a core attribute. Like ComplexCoreAttribute, only the names of top-level
+// attributes are checked, since sub-attributes like "$ref" do not follow the attribute name syntax. Nested complex
+// attributes are allowed, since the "Schema" resource contains them.
+func (raw rawAttribute) parse(isSubAttribute bool) (CoreAttribute, error) {
+	if !isSubAttribute && !isValidAttributeName(raw.Name) {
+		return CoreAttribute{}, fmt.Errorf("invalid attribute name %q", raw.Name)
+	}
+
+	a := CoreAttribute{
+		canonicalValues: raw.CanonicalValues,
+		caseExact:       raw.CaseExact,
+		multiValued:     raw.MultiValued,
+		name:            raw.Name,
+		referenceTypes:  raw.ReferenceTypes,
+		required:        raw.Required,
+	}
+	if raw.Description != "" {
+		a.description = optional.NewString(raw.Description)
+	}
+
+	var err error
+	if a.mutability, err = parseAttributeMutability(raw.Mutability); err != nil {
+		return CoreAttribute{}, fmt.Errorf("attribute %q: %v", raw.Name, err)
+	}
+	if a.returned, err = parseAttributeReturned(raw.Returned); err != nil {
+		return CoreAttribute{}, fmt.Errorf("attribute %q: %v", raw.Name, err)
+	}
+	if a.typ, err = parseAttributeType(raw.Type); err != nil {
+		return CoreAttribute{}, fmt.Errorf("attribute %q: %v", raw.Name, err)
+	}
+	if a.uniqueness, err = parseAttributeUniqueness(raw.Uniqueness); err != nil {
+		return CoreAttribute{}, fmt.Errorf("attribute %q: %v", raw.Name, err)
+	}
+
+	if len(raw.SubAttributes) == 0 {
+		return a, nil
+	}
+	if a.typ != attributeDataTypeComplex {
+		return CoreAttribute{}, fmt.Errorf("attribute %q of type %q can not have sub-attributes", raw.Name, a.typ)
+	}
+
+	names := map[string]int{}
+	for i, rawSub := range raw.SubAttributes {
+		name := strings.ToLower(rawSub.Name)
+		if j, ok := names[name]; ok {
+			return CoreAttribute{}, fmt.Errorf("duplicate name %q for sub-attributes %d and %d of attribute %q", name, i, j, raw.Name)
+		}
+		names[name] = i
+
+		sub, err := rawSub.parse(true)
+		if err != nil {
+			return CoreAttribute{}, err
+		}
+		a.subAttributes = append(a.subAttributes, sub)
+	}
+	return a, nil
+}
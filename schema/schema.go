@@ -2,6 +2,7 @@ package schema
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/elimity-com/scim/errors"
@@ -54,6 +55,50 @@ func (s Schema) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.ToMap())
 }
 
+// UnmarshalJSON converts the json representation of a schema, as defined in RFC 7643 section 7, to the schema struct.
+// It accepts the documents that are returned by MarshalJSON, e.g., by the "/Schemas" endpoint. The characteristics of
+// the attributes are validated, and absent characteristics get their default values.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Attributes  []rawAttribute `json:"attributes"`
+		Description string         `json:"description"`
+		ID          string         `json:"id"`
+		Name        string         `json:"name"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.ID == "" {
+		return fmt.Errorf("schema without id")
+	}
+
+	schema := Schema{ID: raw.ID}
+	if raw.Name != "" {
+		schema.Name = optional.NewString(raw.Name)
+	}
+	if raw.Description != "" {
+		schema.Description = optional.NewString(raw.Description)
+	}
+
+	names := map[string]int{}
+	for i, rawAttr := range raw.Attributes {
+		name := strings.ToLower(rawAttr.Name)
+		if j, ok := names[name]; ok {
+			return fmt.Errorf("duplicate name %q for attributes %d and %d of schema %q", name, i, j, raw.ID)
+		}
+		names[name] = i
+
+		attr, err := rawAttr.parse(false)
+		if err != nil {
+			return fmt.Errorf("schema %q: %v", raw.ID, err)
+		}
+		schema.Attributes = append(schema.Attributes, attr)
+	}
+
+	*s = schema
+	return nil
+}
+
 // ToMap returns the map representation of a schema.
 func (s Schema) ToMap() map[string]interface{} {
 	return map[string]interface{}{
@@ -3,6 +3,7 @@ package schema
 import (
 	"encoding/json"
 	"io/ioutil"
+	"reflect"
 	"testing"
 
 	"github.com/elimity-com/scim/optional"
@@ -100,6 +101,51 @@ func TestJSONMarshalling(t *testing.T) {
 	}
 }
 
+func TestJSONUnmarshalling(t *testing.T) {
+	for _, s := range []Schema{
+		testSchema,
+		CoreUserSchema(),
+		CoreGroupSchema(),
+		ExtensionEnterpriseUser(),
+		ResourceTypeSchema(),
+		Definition(),
+	} {
+		t.Run(s.ID, func(t *testing.T) {
+			raw, err := s.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual Schema
+			if err := json.Unmarshal(raw, &actual); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s, actual) {
+				t.Errorf("schema did not survive a marshal/unmarshal round trip. want %v, got %v", s, actual)
+			}
+		})
+	}
+}
+
+func TestJSONUnmarshallingInvalid(t *testing.T) {
+	for _, test := range []string{
+		`{"attributes": []}`,
+		`{"id": "test", "attributes": [{"name": "_invalid"}]}`,
+		`{"id": "test", "attributes": [{"name": "attr", "mutability": "readwrite"}]}`,
+		`{"id": "test", "attributes": [{"name": "attr", "returned": "sometimes"}]}`,
+		`{"id": "test", "attributes": [{"name": "attr", "uniqueness": "local"}]}`,
+		`{"id": "test", "attributes": [{"name": "attr", "type": "number"}]}`,
+		`{"id": "test", "attributes": [{"name": "attr"}, {"name": "Attr"}]}`,
+		`{"id": "test", "attributes": [{"name": "attr", "subAttributes": [{"name": "sub"}]}]}`,
+		`{"id": "test", "attributes": [{"name": "attr", "type": "complex", "subAttributes": [{"name": "sub"}, {"name": "SUB"}]}]}`,
+	} {
+		var s Schema
+		if err := json.Unmarshal([]byte(test), &s); err == nil {
+			t.Errorf("expected an error for %s", test)
+		}
+	}
+}
+
 func TestResourceInvalid(t *testing.T) {
 	var resource interface{}
 	if _, scimErr := testSchema.Validate(resource); scimErr == nil {
@@ -252,6 +298,25 @@ func TestValidationInvalid(t *testing.T) {
 	}
 }
 
+func TestAttributeCharacteristics(t *testing.T) {
+	attr := SimpleCoreAttribute(SimpleStringParams(StringParams{
+		Mutability: AttributeMutabilityWriteOnly(),
+		Name:       "password",
+		Returned:   AttributeReturnedNever(),
+		Uniqueness: AttributeUniquenessServer(),
+	}))
+	// The characteristics are JSON encoded.
+	if m := attr.Mutability(); m != `"writeOnly"` {
+		t.Errorf("expected mutability \"writeOnly\", got %s", m)
+	}
+	if r := attr.Returned(); r != `"never"` {
+		t.Errorf("expected returned \"never\", got %s", r)
+	}
+	if u := attr.Uniqueness(); u != `"server"` {
+		t.Errorf("expected uniqueness \"server\", got %s", u)
+	}
+}
+
 func normalizeJSON(rawJSON []byte) (string, error) {
 	dataMap := map[string]interface{}{}
 
@@ -0,0 +1,287 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StructTagKey is the key of the struct tags that are read by FromStruct.
+const StructTagKey = "scim"
+
+var (
+	bytesType = reflect.TypeOf([]byte(nil))
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// FromStruct creates a schema with the given id from the exported fields of the given struct, or pointer to a struct.
+// The name and the description of the schema can be set on the returned schema.
+//
+// Each field becomes an attribute. The data type of the attribute is derived from the type of the field: strings
+// become "string" attributes, booleans "boolean" attributes, integers "integer" attributes, floats "decimal"
+// attributes, time.Time values "dateTime" attributes, byte slices "binary" attributes and structs "complex"
+// attributes, of which the fields become the sub-attributes. Slices and arrays become multi-valued attributes of their
+// element type. Pointers are dereferenced and the fields of embedded structs are promoted, like encoding/json does.
+//
+// The attribute is configured by the "scim" struct tag of the field, which consists of the attribute name followed by
+// comma-separated options, e.g., `scim:"userName,required,uniqueness=server"`. If the name is empty, the name of the
+// "json" struct tag is used, or otherwise the field name starting with a lower case letter. A field with the tag
+// `scim:"-"` is ignored. The following options are supported:
+//   - required: the attribute is required.
+//   - caseExact: the attribute is case exact, only for "string" attributes.
+//   - type=<type>: the data type of the attribute, e.g., "reference", "binary" or "dateTime" for a string field.
+//   - mutability=<mutability>: "readOnly", "readWrite", "immutable" or "writeOnly".
+//   - returned=<returned>: "always", "never", "default" or "request".
+//   - uniqueness=<uniqueness>: "none", "server" or "global".
+//   - canonicalValues=<value>|<value>: the canonical values, only for "string" attributes.
+//   - referenceTypes=<type>|<type>: the reference types, only for "reference" attributes.
+//
+// Like the attribute constructors, the characteristics that are fixed for a data type are set accordingly, e.g.,
+// "binary" and "reference" attributes are always case exact. An error is returned if a field has an unsupported type,
+// if a tag is invalid or if a complex attribute contains another complex attribute.
+func FromStruct(id string, v interface{}) (Schema, error) {
+	t := indirectType(reflect.TypeOf(v))
+	if t == nil || t.Kind() != reflect.Struct {
+		return Schema{}, fmt.Errorf("schema %q: expected a struct, got %v", id, reflect.TypeOf(v))
+	}
+
+	attributes, err := structAttributes(t, false, nil)
+	if err != nil {
+		return Schema{}, fmt.Errorf("schema %q: %v", id, err)
+	}
+	return Schema{
+		Attributes: attributes,
+		ID:         id,
+	}, nil
+}
+
+// fieldAttribute creates the attribute of the given struct field, based on its type and its struct tag.
+func fieldAttribute(field reflect.StructField, tag string, isSubAttribute bool) (CoreAttribute, error) {
+	options := strings.Split(tag, ",")
+	a := CoreAttribute{name: options[0]}
+	if a.name == "" {
+		a.name = fieldName(field)
+	}
+	if !isSubAttribute && !isValidAttributeName(a.name) {
+		return CoreAttribute{}, fmt.Errorf("invalid attribute name %q of field %s", a.name, field.Name)
+	}
+
+	var (
+		caseExact  bool
+		typ        string
+		uniqueness string
+		err        error
+	)
+	for _, option := range options[1:] {
+		key, value := option, ""
+		if i := strings.Index(option, "="); i != -1 {
+			key, value = option[:i], option[i+1:]
+		}
+
+		switch key {
+		case "required":
+			a.required = true
+		case "caseExact":
+			caseExact = true
+		case "type":
+			typ = value
+		case "mutability":
+			if a.mutability, err = parseAttributeMutability(value); err != nil {
+				return CoreAttribute{}, fmt.Errorf("attribute %q: %v", a.name, err)
+			}
+		case "returned":
+			if a.returned, err = parseAttributeReturned(value); err != nil {
+				return CoreAttribute{}, fmt.Errorf("attribute %q: %v", a.name, err)
+			}
+		case "uniqueness":
+			uniqueness = value
+			if a.uniqueness, err = parseAttributeUniqueness(value); err != nil {
+				return CoreAttribute{}, fmt.Errorf("attribute %q: %v", a.name, err)
+			}
+		case "canonicalValues":
+			a.canonicalValues = strings.Split(value, "|")
+		case "referenceTypes":
+			for _, referenceType := range strings.Split(value, "|") {
+				a.referenceTypes = append(a.referenceTypes, AttributeReferenceType(referenceType))
+			}
+		default:
+			return CoreAttribute{}, fmt.Errorf("attribute %q: invalid struct tag option %q", a.name, option)
+		}
+	}
+
+	t := indirectType(field.Type)
+	if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t != bytesType {
+		a.multiValued = true
+		t = indirectType(t.Elem())
+	}
+
+	types := fieldAttributeTypes(t)
+	if len(types) == 0 {
+		return CoreAttribute{}, fmt.Errorf("attribute %q: unsupported field type %v", a.name, field.Type)
+	}
+	a.typ = types[0]
+	if typ != "" {
+		if a.typ, err = parseAttributeType(typ); err != nil {
+			return CoreAttribute{}, fmt.Errorf("attribute %q: %v", a.name, err)
+		}
+		if !containsAttributeType(types, a.typ) {
+			return CoreAttribute{}, fmt.Errorf("attribute %q: type %q does not match field type %v", a.name, a.typ, field.Type)
+		}
+	}
+
+	if caseExact && a.typ != attributeDataTypeString {
+		return CoreAttribute{}, fmt.Errorf("attribute %q: caseExact is not applicable to type %q", a.name, a.typ)
+	}
+	if a.canonicalValues != nil && a.typ != attributeDataTypeString {
+		return CoreAttribute{}, fmt.Errorf("attribute %q: canonicalValues are not applicable to type %q", a.name, a.typ)
+	}
+	if a.referenceTypes != nil && a.typ != attributeDataTypeReference {
+		return CoreAttribute{}, fmt.Errorf("attribute %q: referenceTypes are not applicable to type %q", a.name, a.typ)
+	}
+
+	switch a.typ {
+	case attributeDataTypeBinary, attributeDataTypeBoolean, attributeDataTypeDateTime:
+		if uniqueness != "" {
+			return CoreAttribute{}, fmt.Errorf("attribute %q: uniqueness is not applicable to type %q", a.name, a.typ)
+		}
+		a.caseExact = a.typ == attributeDataTypeBinary
+	case attributeDataTypeReference:
+		a.caseExact = true
+	case attributeDataTypeComplex:
+		if isSubAttribute {
+			return CoreAttribute{}, fmt.Errorf("sub-attribute %q can not be complex", a.name)
+		}
+		if a.subAttributes, err = structAttributes(t, true, nil); err != nil {
+			return CoreAttribute{}, fmt.Errorf("attribute %q: %v", a.name, err)
+		}
+	default:
+		a.caseExact = caseExact
+	}
+	return a, nil
+}
+
+// structAttributes creates the attributes of the exported fields of the given struct type. The embedding types are the
+// struct types whose fields are currently being promoted, these are used to detect embedding cycles.
+func structAttributes(t reflect.Type, isSubAttribute bool, embedding []reflect.Type) (Attributes, error) {
+	for _, e := range embedding {
+		if e == t {
+			return nil, fmt.Errorf("type %s embeds itself", t)
+		}
+	}
+	embedding = append(embedding, t)
+
+	var attributes Attributes
+	names := map[string]string{}
+	add := func(field string, a CoreAttribute) error {
+		name := strings.ToLower(a.name)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("duplicate name %q for fields %s and %s", name, other, field)
+		}
+		names[name] = field
+		attributes = append(attributes, a)
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, hasTag := field.Tag.Lookup(StructTagKey)
+		if tag == "-" {
+			continue
+		}
+
+		if embedded := indirectType(field.Type); field.Anonymous && !hasTag && embedded.Kind() == reflect.Struct {
+			promoted, err := structAttributes(embedded, isSubAttribute, embedding)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range promoted {
+				if err := add(field.Name, a); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if field.PkgPath != "" || (!hasTag && jsonName(field) == "-") {
+			// Unexported fields and fields that are ignored by encoding/json.
+			continue
+		}
+
+		a, err := fieldAttribute(field, tag, isSubAttribute)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(field.Name, a); err != nil {
+			return nil, err
+		}
+	}
+	return attributes, nil
+}
+
+// containsAttributeType returns whether the given types contain the given type.
+func containsAttributeType(types []attributeType, typ attributeType) bool {
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldAttributeTypes returns the attribute types that can represent values of the given type. The first type is the
+// default.
+func fieldAttributeTypes(t reflect.Type) []attributeType {
+	switch t {
+	case bytesType:
+		return []attributeType{attributeDataTypeBinary}
+	case timeType:
+		return []attributeType{attributeDataTypeDateTime}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return []attributeType{
+			attributeDataTypeString,
+			attributeDataTypeReference,
+			attributeDataTypeBinary,
+			attributeDataTypeDateTime,
+		}
+	case reflect.Bool:
+		return []attributeType{attributeDataTypeBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []attributeType{attributeDataTypeInteger}
+	case reflect.Float32, reflect.Float64:
+		return []attributeType{attributeDataTypeDecimal}
+	case reflect.Struct:
+		return []attributeType{attributeDataTypeComplex}
+	default:
+		return nil
+	}
+}
+
+// fieldName returns the attribute name of the given field if its struct tag does not contain one: the name of the
+// json struct tag, or otherwise the field name starting with a lower case letter.
+func fieldName(field reflect.StructField) string {
+	if name := jsonName(field); name != "" && name != "-" {
+		return name
+	}
+	r, size := utf8.DecodeRuneInString(field.Name)
+	return string(unicode.ToLower(r)) + field.Name[size:]
+}
+
+// indirectType returns the type that the given (pointer) type points to.
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// jsonName returns the name of the json struct tag of the given field.
+func jsonName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
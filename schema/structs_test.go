@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testStructBase struct {
+	ID       string `scim:"-"`
+	UserName string `scim:"userName,required,caseExact,uniqueness=server"`
+}
+
+type testStructEmail struct {
+	Value   string `scim:",required"`
+	Type    string `scim:"type,canonicalValues=work|home|other"`
+	Primary bool
+}
+
+type testStructName struct {
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  *string
+}
+
+type testStruct struct {
+	testStructBase
+	Password  string `json:"password" scim:",mutability=writeOnly,returned=never"`
+	Active    *bool
+	Age       int
+	Score     float64
+	Birthday  time.Time
+	Photo     []byte
+	Profile   string `scim:"profileUrl,type=reference,referenceTypes=external"`
+	Name      testStructName
+	Emails    []testStructEmail
+	Nicknames []string `json:"nickNames"`
+	Manager   string   `scim:"manager,type=reference,referenceTypes=User|Group,mutability=readOnly,returned=always"`
+	Ignored   string   `json:"-"`
+	internal  string
+}
+
+func TestFromStruct(t *testing.T) {
+	expected := Schema{
+		ID: "urn:example:test",
+		Attributes: Attributes{
+			SimpleCoreAttribute(SimpleStringParams(StringParams{
+				CaseExact:  true,
+				Name:       "userName",
+				Required:   true,
+				Uniqueness: AttributeUniquenessServer(),
+			})),
+			SimpleCoreAttribute(SimpleStringParams(StringParams{
+				Mutability: AttributeMutabilityWriteOnly(),
+				Name:       "password",
+				Returned:   AttributeReturnedNever(),
+			})),
+			SimpleCoreAttribute(SimpleBooleanParams(BooleanParams{Name: "active"})),
+			SimpleCoreAttribute(SimpleNumberParams(NumberParams{Name: "age", Type: AttributeTypeInteger()})),
+			SimpleCoreAttribute(SimpleNumberParams(NumberParams{Name: "score", Type: AttributeTypeDecimal()})),
+			SimpleCoreAttribute(SimpleDateTimeParams(DateTimeParams{Name: "birthday"})),
+			SimpleCoreAttribute(SimpleBinaryParams(BinaryParams{Name: "photo"})),
+			SimpleCoreAttribute(SimpleReferenceParams(ReferenceParams{
+				Name:           "profileUrl",
+				ReferenceTypes: []AttributeReferenceType{AttributeReferenceTypeExternal},
+			})),
+			ComplexCoreAttribute(ComplexParams{
+				Name: "name",
+				SubAttributes: []SimpleParams{
+					SimpleStringParams(StringParams{Name: "familyName"}),
+					SimpleStringParams(StringParams{Name: "givenName"}),
+				},
+			}),
+			ComplexCoreAttribute(ComplexParams{
+				MultiValued: true,
+				Name:        "emails",
+				SubAttributes: []SimpleParams{
+					SimpleStringParams(StringParams{Name: "value", Required: true}),
+					SimpleStringParams(StringParams{
+						CanonicalValues: []string{"work", "home", "other"},
+						Name:            "type",
+					}),
+					SimpleBooleanParams(BooleanParams{Name: "primary"}),
+				},
+			}),
+			SimpleCoreAttribute(SimpleStringParams(StringParams{MultiValued: true, Name: "nickNames"})),
+			SimpleCoreAttribute(SimpleReferenceParams(ReferenceParams{
+				Mutability:     AttributeMutabilityReadOnly(),
+				Name:           "manager",
+				ReferenceTypes: []AttributeReferenceType{"User", "Group"},
+				Returned:       AttributeReturnedAlways(),
+			})),
+		},
+	}
+
+	for _, v := range []interface{}{testStruct{}, &testStruct{}} {
+		actual, err := FromStruct("urn:example:test", v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("schema did not match the expected schema. want %v, got %v", expected, actual)
+		}
+	}
+}
+
+type recursiveStruct struct {
+	*recursiveStruct
+	Value string
+}
+
+type recursiveA struct{ *recursiveB }
+
+type recursiveB struct{ *recursiveA }
+
+func TestFromStructInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "not a struct", v: "test"},
+		{name: "nil", v: nil},
+		{name: "unsupported type", v: struct{ Map map[string]string }{}},
+		{name: "invalid name", v: struct {
+			Field string `scim:"_field"`
+		}{}},
+		{name: "invalid option", v: struct {
+			Field string `scim:",optional"`
+		}{}},
+		{name: "invalid mutability", v: struct {
+			Field string `scim:",mutability=readwrite"`
+		}{}},
+		{name: "invalid returned", v: struct {
+			Field string `scim:",returned=sometimes"`
+		}{}},
+		{name: "invalid uniqueness", v: struct {
+			Field string `scim:",uniqueness=local"`
+		}{}},
+		{name: "invalid type", v: struct {
+			Field string `scim:",type=number"`
+		}{}},
+		{name: "mismatching type", v: struct {
+			Field int `scim:",type=string"`
+		}{}},
+		{name: "case exact boolean", v: struct {
+			Field bool `scim:",caseExact"`
+		}{}},
+		{name: "canonical values integer", v: struct {
+			Field int `scim:",canonicalValues=1|2"`
+		}{}},
+		{name: "reference types string", v: struct {
+			Field string `scim:",referenceTypes=User"`
+		}{}},
+		{name: "unique boolean", v: struct {
+			Field bool `scim:",uniqueness=server"`
+		}{}},
+		{name: "duplicate names", v: struct {
+			Field  string
+			Field2 string `scim:"field"`
+		}{}},
+		{name: "nested complex", v: struct {
+			Field struct{ Sub struct{ Value string } }
+		}{}},
+		{name: "embedding cycle", v: recursiveStruct{}},
+		{name: "indirect embedding cycle", v: struct{ *recursiveA }{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := FromStruct("urn:example:test", test.v); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerResourcesSearchHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/Users/.search", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+		"filter": "userName sw \"test\"",
+		"startIndex": 1,
+		"count": 5
+	}`))
+	rr := httptest.NewRecorder()
+	newTestServer().ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response listResponse
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertEqual(t, 20, response.TotalResults)
+	assertEqual(t, 5, response.ItemsPerPage)
+	assertLen(t, response.Resources, 5)
+}
+
+func TestServerResourcesSearchHandlerInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "invalid schema",
+			body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:ListResponse"]}`,
+		}, {
+			name: "invalid filter",
+			body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "filter": "invalid eq \"test\""}`,
+		}, {
+			name: "invalid count",
+			body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "count": "ten"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/Users/.search", strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			newTestServer().ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestServerRootSearchHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v2/.search", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+		"filter": "displayName pr",
+		"startIndex": 15,
+		"count": 10
+	}`))
+	rr := httptest.NewRecorder()
+	newTestServer().ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response listResponse
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+	// Three resource types with twenty resources each.
+	assertEqual(t, 60, response.TotalResults)
+	assertEqual(t, 15, response.StartIndex)
+	assertLen(t, response.Resources, 10)
+
+	// The page contains the last six users and the first four enterprise users.
+	resourceTypes := make(map[string]int)
+	for _, resource := range response.Resources {
+		meta := resource.(map[string]interface{})["meta"].(map[string]interface{})
+		resourceTypes[meta["resourceType"].(string)]++
+	}
+	assertEqual(t, 6, resourceTypes["User"])
+	assertEqual(t, 4, resourceTypes["EnterpriseUser"])
+}
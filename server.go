@@ -1,11 +1,15 @@
 package scim
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/elimity-com/scim/errors"
 	f "github.com/elimity-com/scim/filter"
@@ -14,13 +18,17 @@ import (
 )
 
 const (
-	defaultStartIndex = 1
-	fallbackCount     = 100
+	defaultStartIndex         = 1
+	fallbackCount             = 100
+	fallbackBulkMaxOperations = 1000
+	fallbackMaxPayloadSize    = 1048576
+
+	searchRequestSchema = "urn:ietf:params:scim:api:messages:2.0:SearchRequest"
 )
 
-// getFilter returns a validated filter if present in the url query, nil otherwise.
-func getFilter(r *http.Request, s schema.Schema, extensions ...schema.Schema) (filter.Expression, error) {
-	filter := strings.TrimSpace(r.URL.Query().Get("filter"))
+// getFilter returns a validated filter if present in the given parameters, nil otherwise.
+func getFilter(values url.Values, s schema.Schema, extensions ...schema.Schema) (filter.Expression, error) {
+	filter := strings.TrimSpace(values.Get("filter"))
 	if filter == "" {
 		return nil, nil // No filter present.
 	}
@@ -35,8 +43,46 @@ func getFilter(r *http.Request, s schema.Schema, extensions ...schema.Schema) (f
 	return validator.GetFilter(), nil
 }
 
-func getIntQueryParam(r *http.Request, key string, def int) (int, error) {
-	strVal := r.URL.Query().Get(key)
+// getSortBy returns a validated attribute path if the "sortBy" parameter is present in the given parameters, nil
+// otherwise. Besides the given schemas, the path can also refer to the common attributes (e.g. "meta.lastModified").
+func getSortBy(values url.Values, s schema.Schema, extensions ...schema.Schema) (*filter.AttributePath, error) {
+	sortBy := strings.TrimSpace(values.Get("sortBy"))
+	if sortBy == "" {
+		return nil, nil // No sortBy present.
+	}
+
+	refExtensions := append([]schema.Schema{{
+		ID:         s.ID,
+		Attributes: schema.CommonAttributes(),
+	}}, extensions...)
+	validator, err := f.NewPathValidator(sortBy, s, refExtensions...)
+	if err != nil {
+		return nil, err
+	}
+	if err := validator.Validate(); err != nil {
+		return nil, err
+	}
+	path := validator.Path()
+	if path.ValueExpression != nil {
+		return nil, fmt.Errorf("the sortBy parameter can not contain a value expression: %s", sortBy)
+	}
+	return &path.AttributePath, nil
+}
+
+// getSortOrder returns the sort order in the given parameters. It defaults to ascending if not present.
+func getSortOrder(values url.Values) (SortOrder, error) {
+	switch sortOrder := strings.TrimSpace(values.Get("sortOrder")); {
+	case sortOrder == "", strings.EqualFold(sortOrder, string(SortOrderAscending)):
+		return SortOrderAscending, nil
+	case strings.EqualFold(sortOrder, string(SortOrderDescending)):
+		return SortOrderDescending, nil
+	default:
+		return "", fmt.Errorf("invalid query parameter, \"sortOrder\" must be \"ascending\" or \"descending\"")
+	}
+}
+
+func getIntQueryParam(values url.Values, key string, def int) (int, error) {
+	strVal := values.Get(key)
 
 	if strVal == "" {
 		return def, nil
@@ -49,6 +95,91 @@ func getIntQueryParam(r *http.Request, key string, def int) (int, error) {
 	return 0, fmt.Errorf("invalid query parameter, \"%s\" must be an integer", key)
 }
 
+// limitPayload reads the body of the given POST, PUT or PATCH request, so that it can be read again by the handlers.
+// It returns a 413 SCIM error if the size of the body exceeds the maximum payload size. The size of bulk requests is
+// limited by the bulk handler itself.
+func (s Server) limitPayload(r *http.Request) *errors.ScimError {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil
+	}
+
+	maxPayloadSize := s.Config.getMaxPayloadSize()
+	tooLarge := errors.ScimErrorRequestEntityTooLarge(fmt.Sprintf(
+		"The size of the request exceeds the maxPayloadSize (%d).", maxPayloadSize,
+	))
+	if r.ContentLength > int64(maxPayloadSize) {
+		return &tooLarge
+	}
+	if r.Body == nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(maxPayloadSize)+1))
+	if err != nil {
+		return &errors.ScimErrorInvalidSyntax
+	}
+	if len(data) > maxPayloadSize {
+		return &tooLarge
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return nil
+}
+
+// parseSearchRequest parses the body of a search request, i.e. a POST request to "/.search", into the same parameters
+// that are used in the url query of a GET request.
+func parseSearchRequest(r *http.Request) (url.Values, *errors.ScimError) {
+	data, err := readBody(r)
+	if err != nil {
+		return nil, &errors.ScimErrorInvalidSyntax
+	}
+
+	var req struct {
+		Schemas            []string
+		Attributes         []string
+		ExcludedAttributes []string
+		Filter             string
+		SortBy             string
+		SortOrder          string
+		StartIndex         *int
+		Count              *int
+	}
+	if err := unmarshal(data, &req); err != nil {
+		return nil, &errors.ScimErrorInvalidSyntax
+	}
+
+	// The body of each request MUST contain the "schemas" attribute with the URI value of
+	// "urn:ietf:params:scim:api:messages:2.0:SearchRequest".
+	if len(req.Schemas) != 1 || req.Schemas[0] != searchRequestSchema {
+		return nil, &errors.ScimErrorInvalidValue
+	}
+
+	values := make(url.Values)
+	if len(req.Attributes) != 0 {
+		values.Set("attributes", strings.Join(req.Attributes, ","))
+	}
+	if len(req.ExcludedAttributes) != 0 {
+		values.Set("excludedAttributes", strings.Join(req.ExcludedAttributes, ","))
+	}
+	if req.Filter != "" {
+		values.Set("filter", req.Filter)
+	}
+	if req.SortBy != "" {
+		values.Set("sortBy", req.SortBy)
+	}
+	if req.SortOrder != "" {
+		values.Set("sortOrder", req.SortOrder)
+	}
+	if req.StartIndex != nil {
+		values.Set("startIndex", strconv.Itoa(*req.StartIndex))
+	}
+	if req.Count != nil {
+		values.Set("count", strconv.Itoa(*req.Count))
+	}
+	return values, nil
+}
+
 func parseIdentifier(path, endpoint string) (string, error) {
 	return url.PathUnescape(strings.TrimPrefix(path, endpoint+"/"))
 }
@@ -58,19 +189,115 @@ func parseIdentifier(path, endpoint string) (string, error) {
 type Server struct {
 	Config        ServiceProviderConfig
 	ResourceTypes []ResourceType
+	// MeResolver resolves the resource of the authenticated subject for requests to the "/Me" endpoint. The "/Me"
+	// endpoint is not supported if no resolver is given.
+	MeResolver MeResolver
+	// BaseURL is the absolute URL at which the SCIM endpoints are served, e.g., "https://example.com/scim/v2". It is
+	// used for the "meta.location" attributes and the "Location" headers of the responses. If no base URL is given and
+	// DeriveBaseURL is false, all locations are relative to the base URL, e.g., "Users/{id}".
+	BaseURL string
+	// DeriveBaseURL indicates whether the base URL is derived from the scheme and host of each request if no BaseURL
+	// is given. The "/v2" version prefix is preserved.
+	DeriveBaseURL bool
+	// TrustForwardedHeaders indicates whether the "Forwarded", "X-Forwarded-Proto", "X-Forwarded-Host" and
+	// "X-Forwarded-Prefix" headers are used to derive the base URL. Only enable this if the server is behind a proxy
+	// that sets (or strips) these headers, since they are controlled by the client otherwise.
+	TrustForwardedHeaders bool
+	// BeforeHooks are called in order before a create, replace, patch or delete operation is passed to the handler of
+	// the resource type. They can rewrite the input of the operation or veto it by returning an error.
+	BeforeHooks []BeforeHook
+	// AfterHooks are called in order after a create, replace, patch or delete operation is successfully handled by the
+	// handler of the resource type. They can rewrite the resource that is returned to the client.
+	AfterHooks []AfterHook
+	// Authenticators authenticate the clients of the requests, based on the authentication scheme of the
+	// "Authorization" header. Requests that can not be authenticated get a 401 Unauthorized response. No
+	// authentication is done if no authenticators are given.
+	Authenticators []Authenticator
+	// PublicDiscovery indicates whether the discovery endpoints, i.e. "/ServiceProviderConfig", "/Schemas" and
+	// "/ResourceTypes", can be accessed without authentication.
+	PublicDiscovery bool
+	// Authorization limits the operations that clients can perform and the attributes that they can access. All
+	// authenticated clients can perform all operations if nil. Operations are authorized before the handler of the
+	// resource type is called, including the Get calls to evaluate the "If-Match" header.
+	Authorization *AuthorizationPolicy
+	// TenantResolver resolves the tenant of each request, e.g., TenantFromPathPrefix, TenantFromHost or
+	// TenantFromClaim. The tenant is available to the handlers with TenantFromContext. Requests of which the tenant can
+	// not be resolved get a 404 Not Found response, except for public discovery endpoints.
+	TenantResolver TenantResolver
+	// TenantAuthorizer checks whether the authenticated client of each request belongs to its tenant, e.g.,
+	// TenantClaimAuthorizer. Requests of clients that do not belong to the tenant get a 403 Forbidden response. It
+	// should be set if the tenant is resolved from the request itself, e.g., with TenantFromPathPrefix or
+	// TenantFromHost, otherwise the clients of one tenant can access the resources of all tenants.
+	TenantAuthorizer TenantAuthorizer
+	// Tenants maps the identifiers of the tenants on their customizations, e.g., their own service provider config or
+	// schema extensions. Requests of tenants that are not in the map get a 404 Not Found response, unless the map is
+	// nil, in which case all tenants are served with the configuration of the server.
+	Tenants map[string]Tenant
+	// Logger writes a structured log record for each request, with the resource type, the operation, the status, the
+	// SCIM error type and the latency, and records for internal failures. Only failures are logged, with the standard
+	// logger of the log package, if no logger is given.
+	Logger Logger
+	// Observer is notified of the start and the end of each request, and of the phases in between, e.g., the
+	// validation of the request body and the calls to the resource handlers. See PrometheusObserver.
+	Observer Observer
 }
 
 // ServeHTTP dispatches the request to the handler whose pattern most closely matches the request URL.
 func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/scim+json")
 
+	start, recorder := time.Now(), &statusRecorder{ResponseWriter: w}
+	w = recorder
+	r, record := withRequestLog(r, s.logger())
+	ctx := r.Context()
+	if s.Observer != nil {
+		ctx = s.Observer.RequestStart(ctx, RequestObservation{
+			Method: r.Method,
+			Path:   r.URL.Path,
+		})
+		r = r.WithContext(ctx)
+	}
+	defer func() {
+		s.endRequest(ctx, r, record, recorder.status, time.Since(start))
+	}()
+
+	r, scimErr := s.resolveTenant(r)
+	if scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+
 	path := strings.TrimPrefix(r.URL.Path, "/v2")
+	record.endpoint = endpointPattern(path)
+
+	public := s.PublicDiscovery && isDiscoveryEndpoint(path)
+	if !public {
+		if r, scimErr = s.authenticate(r); scimErr != nil {
+			if scimErr.Status == http.StatusUnauthorized {
+				s.challenges(w, r)
+			}
+			errorHandler(w, r, scimErr)
+			return
+		}
+	}
+
+	if s, r, scimErr = s.forTenant(r, public); scimErr != nil {
+		errorHandler(w, r, scimErr)
+		return
+	}
+	r = s.withBaseURL(r)
+	s = s.observed()
+
+	if path != "/Bulk" {
+		if scimErr := s.limitPayload(r); scimErr != nil {
+			errorHandler(w, r, scimErr)
+			return
+		}
+	}
 
 	switch {
 	case path == "/Me":
-		errorHandler(w, r, &errors.ScimError{
-			Status: http.StatusNotImplemented,
-		})
+		s.meHandler(w, r)
 		return
 	case path == "/Schemas" && r.Method == http.MethodGet:
 		s.schemasHandler(w, r)
@@ -84,20 +311,49 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case strings.HasPrefix(path, "/ResourceTypes/") && r.Method == http.MethodGet:
 		s.resourceTypeHandler(w, r, strings.TrimPrefix(path, "/ResourceTypes/"))
 		return
+	case (path == "" || path == "/") && r.Method == http.MethodGet:
+		s.rootGetHandler(w, r)
+		return
+	case path == "/.search" && r.Method == http.MethodPost:
+		s.rootSearchHandler(w, r)
+		return
+	case path == "/Bulk" && r.Method == http.MethodPost:
+		s.bulkHandler(w, r)
+		return
 	case path == "/ServiceProviderConfig":
 		s.serviceProviderConfigHandler(w, r)
 		return
 	}
 
+	if s.serveResource(w, r, path) {
+		return
+	}
+
+	errorHandler(w, r, &errors.ScimError{
+		Detail: "Specified endpoint does not exist.",
+		Status: http.StatusNotFound,
+	})
+}
+
+// serveResource dispatches the request to the resource type whose endpoint matches the given path. It returns false
+// if none of the resource types could handle the request.
+func (s Server) serveResource(w http.ResponseWriter, r *http.Request, path string) bool {
 	for _, resourceType := range s.ResourceTypes {
+		if path == resourceType.Endpoint+"/.search" && r.Method == http.MethodPost {
+			setOperation(r, resourceType.Endpoint+"/.search", resourceType, OperationRead)
+			s.resourcesSearchHandler(w, r, resourceType)
+			return true
+		}
+
 		if path == resourceType.Endpoint {
+			setOperation(r, resourceType.Endpoint, resourceType, operationOf(r.Method))
 			switch r.Method {
 			case http.MethodPost:
 				s.resourcePostHandler(w, r, resourceType)
-				return
+				return true
 			case http.MethodGet:
 				s.resourcesGetHandler(w, r, resourceType)
-				return
+				return true
 			}
 		}
 
@@ -107,27 +363,35 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				break
 			}
 
+			setOperation(r, resourceType.Endpoint+"/{id}", resourceType, operationOf(r.Method))
 			switch r.Method {
 			case http.MethodGet:
 				s.resourceGetHandler(w, r, id, resourceType)
-				return
+				return true
 			case http.MethodPut:
 				s.resourcePutHandler(w, r, id, resourceType)
-				return
+				return true
 			case http.MethodPatch:
 				s.resourcePatchHandler(w, r, id, resourceType)
-				return
+				return true
 			case http.MethodDelete:
 				s.resourceDeleteHandler(w, r, id, resourceType)
-				return
+				return true
 			}
 		}
 	}
 
-	errorHandler(w, r, &errors.ScimError{
-		Detail: "Specified endpoint does not exist.",
-		Status: http.StatusNotFound,
-	})
+	return false
+}
+
+// getResourceType returns the resource type with the given name.
+func (s Server) getResourceType(name string) (ResourceType, bool) {
+	for _, resourceType := range s.ResourceTypes {
+		if resourceType.Name == name {
+			return resourceType, true
+		}
+	}
+	return ResourceType{}, false
 }
 
 // getSchema extracts the schemas from the resources types defined in the server with given id.
@@ -145,6 +409,16 @@ func (s Server) getSchema(id string) schema.Schema {
 	return schema.Schema{}
 }
 
+// schemaResource returns the representation of the given schema, including its "meta" attribute.
+func (s Server) schemaResource(r *http.Request, sc schema.Schema) map[string]interface{} {
+	resource := sc.ToMap()
+	resource["meta"] = meta{
+		ResourceType: "Schema",
+		Location:     s.location(r, "Schemas/"+sc.ID),
+	}
+	return resource
+}
+
 // getSchemas extracts all the schemas from the resources types defined in the server. Duplicate IDs will be ignored.
 func (s Server) getSchemas() []schema.Schema {
 	ids := make([]string, 0)
@@ -164,11 +438,33 @@ func (s Server) getSchemas() []schema.Schema {
 	return schemas
 }
 
+// getUnionSchema returns a schema that contains the attributes of the schemas of all the resource types, including the
+// common attributes. Duplicate attribute names will be ignored.
+func (s Server) getUnionSchema() schema.Schema {
+	var attributes schema.Attributes
+	for _, resourceType := range s.ResourceTypes {
+		for _, attribute := range resourceType.schemaWithCommon().Attributes {
+			if _, ok := attributes.ContainsAttribute(attribute.Name()); !ok {
+				attributes = append(attributes, attribute)
+			}
+		}
+	}
+	return schema.Schema{
+		Attributes: attributes,
+	}
+}
+
 func (s Server) parseRequestParams(r *http.Request, refSchema schema.Schema, refExtensions ...schema.Schema) (ListRequestParams, *errors.ScimError) {
+	return s.parseListRequestParams(r.URL.Query(), refSchema, refExtensions...)
+}
+
+// parseListRequestParams parses the given parameters, either originating from the url query or from the body of a
+// search request, into list request parameters.
+func (s Server) parseListRequestParams(values url.Values, refSchema schema.Schema, refExtensions ...schema.Schema) (ListRequestParams, *errors.ScimError) {
 	invalidParams := make([]string, 0)
 
 	defaultCount := s.Config.getItemsPerPage()
-	count, countErr := getIntQueryParam(r, "count", defaultCount)
+	count, countErr := getIntQueryParam(values, "count", defaultCount)
 	if countErr != nil {
 		invalidParams = append(invalidParams, "count")
 	}
@@ -181,7 +477,7 @@ func (s Server) parseRequestParams(r *http.Request, refSchema schema.Schema, ref
 		count = 0
 	}
 
-	startIndex, indexErr := getIntQueryParam(r, "startIndex", defaultStartIndex)
+	startIndex, indexErr := getIntQueryParam(values, "startIndex", defaultStartIndex)
 	if indexErr != nil {
 		invalidParams = append(invalidParams, "startIndex")
 	}
@@ -189,19 +485,54 @@ func (s Server) parseRequestParams(r *http.Request, refSchema schema.Schema, ref
 		startIndex = defaultStartIndex
 	}
 
-	if len(invalidParams) > 1 {
+	// Invalid "count" and "startIndex" parameters are replaced by their default value, unless both of them are
+	// invalid. Other invalid parameters are always rejected.
+	invalidPagingParams := len(invalidParams)
+
+	attributes, attributesErr := getAttributePaths(values, "attributes")
+	if attributesErr != nil {
+		invalidParams = append(invalidParams, "attributes")
+	}
+
+	excludedAttributes, excludedAttributesErr := getAttributePaths(values, "excludedAttributes")
+	if excludedAttributesErr != nil {
+		invalidParams = append(invalidParams, "excludedAttributes")
+	}
+
+	// The "sortBy" and "sortOrder" parameters are ignored if sorting is not supported.
+	var sortBy *filter.AttributePath
+	if s.Config.SupportSort {
+		var sortByErr error
+		if sortBy, sortByErr = getSortBy(values, refSchema, refExtensions...); sortByErr != nil {
+			invalidParams = append(invalidParams, "sortBy")
+		}
+	}
+
+	var sortOrder SortOrder
+	if sortBy != nil {
+		var sortOrderErr error
+		if sortOrder, sortOrderErr = getSortOrder(values); sortOrderErr != nil {
+			invalidParams = append(invalidParams, "sortOrder")
+		}
+	}
+
+	if invalidPagingParams > 1 || len(invalidParams) > invalidPagingParams {
 		scimErr := errors.ScimErrorBadParams(invalidParams)
 		return ListRequestParams{}, &scimErr
 	}
 
-	reqFilter, err := getFilter(r, refSchema, refExtensions...)
+	reqFilter, err := getFilter(values, refSchema, refExtensions...)
 	if err != nil {
 		return ListRequestParams{}, &errors.ScimErrorInvalidFilter
 	}
 
 	return ListRequestParams{
-		Count:      count,
-		Filter:     reqFilter,
-		StartIndex: startIndex,
+		Attributes:         attributes,
+		Count:              count,
+		ExcludedAttributes: excludedAttributes,
+		Filter:             reqFilter,
+		SortBy:             sortBy,
+		SortOrder:          sortOrder,
+		StartIndex:         startIndex,
 	}, nil
 }
@@ -47,10 +47,53 @@ type ServiceProviderConfig struct {
 	AuthenticationSchemes []AuthenticationScheme
 	// MaxResults denotes the the integer value specifying the maximum number of resources returned in a response. It defaults to 100.
 	MaxResults int
+	// SupportBulk whether your SCIM implementation will support bulk requests.
+	SupportBulk bool
+	// BulkMaxOperations is the maximum number of operations in a single bulk request. It defaults to 1000.
+	BulkMaxOperations int
+	// BulkMaxPayloadSize is the maximum payload size of a single bulk request in bytes. It defaults to MaxPayloadSize.
+	BulkMaxPayloadSize int
+	// MaxPayloadSize is the maximum payload size of a single POST, PUT or PATCH request in bytes. Larger requests get a
+	// 413 Request Entity Too Large response. It defaults to 1048576. The service provider config of RFC 7643 only
+	// advertises the maximum payload size of bulk requests, so this limit is not discoverable by clients unless
+	// BulkMaxPayloadSize is left unset.
+	MaxPayloadSize int
+	// SupportETag whether your SCIM implementation will support entity-tags, i.e. conditional requests using the
+	// "If-Match" and "If-None-Match" headers.
+	SupportETag bool
 	// SupportFiltering whether you SCIM implementation will support filtering.
 	SupportFiltering bool
 	// SupportPatch whether your SCIM implementation will support patch requests.
 	SupportPatch bool
+	// SupportSort whether your SCIM implementation will support sorting. The "sortBy" and "sortOrder" parameters are
+	// ignored if sorting is not supported.
+	SupportSort bool
+}
+
+// getBulkMaxOperations retrieves the configured maximum number of bulk operations. It falls back to 1000 when not
+// configured.
+func (config ServiceProviderConfig) getBulkMaxOperations() int {
+	if config.BulkMaxOperations < 1 {
+		return fallbackBulkMaxOperations
+	}
+	return config.BulkMaxOperations
+}
+
+// getBulkMaxPayloadSize retrieves the configured maximum bulk payload size. It falls back to the maximum payload size
+// when not configured.
+func (config ServiceProviderConfig) getBulkMaxPayloadSize() int {
+	if config.BulkMaxPayloadSize < 1 {
+		return config.getMaxPayloadSize()
+	}
+	return config.BulkMaxPayloadSize
+}
+
+// getMaxPayloadSize retrieves the configured maximum payload size. It falls back to 1048576 when not configured.
+func (config ServiceProviderConfig) getMaxPayloadSize() int {
+	if config.MaxPayloadSize < 1 {
+		return fallbackMaxPayloadSize
+	}
+	return config.MaxPayloadSize
 }
 
 // getItemsPerPage retrieves the configured default count. It falls back to 100 when not configured.
@@ -61,7 +104,7 @@ func (config ServiceProviderConfig) getItemsPerPage() int {
 	return config.MaxResults
 }
 
-func (config ServiceProviderConfig) getRaw() map[string]interface{} {
+func (config ServiceProviderConfig) getRaw(baseURL string) map[string]interface{} {
 	return map[string]interface{}{
 		"schemas":          []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
 		"documentationUri": config.DocumentationURI.Value(),
@@ -69,9 +112,9 @@ func (config ServiceProviderConfig) getRaw() map[string]interface{} {
 			"supported": config.SupportPatch,
 		},
 		"bulk": map[string]interface{}{
-			"supported":      false,
-			"maxOperations":  1000,
-			"maxPayloadSize": 1048576,
+			"supported":      config.SupportBulk,
+			"maxOperations":  config.getBulkMaxOperations(),
+			"maxPayloadSize": config.getBulkMaxPayloadSize(),
 		},
 		"filter": map[string]interface{}{
 			"supported":  config.SupportFiltering,
@@ -81,12 +124,16 @@ func (config ServiceProviderConfig) getRaw() map[string]interface{} {
 			"supported": false,
 		},
 		"sort": map[string]bool{
-			"supported": false,
+			"supported": config.SupportSort,
 		},
 		"etag": map[string]bool{
-			"supported": false,
+			"supported": config.SupportETag,
 		},
 		"authenticationSchemes": config.getRawAuthenticationSchemes(),
+		"meta": meta{
+			ResourceType: "ServiceProviderConfig",
+			Location:     joinLocation(baseURL, "ServiceProviderConfig"),
+		},
 	}
 }
 
@@ -0,0 +1,233 @@
+package scim
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	datetime "github.com/di-wu/xsd-datetime"
+	"github.com/elimity-com/scim/internal/numeric"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// SortOrder represents the order in which the "sortBy" parameter is applied.
+type SortOrder string
+
+const (
+	// SortOrderAscending indicates that the resources are sorted in ascending order. This is the default order.
+	SortOrderAscending SortOrder = "ascending"
+	// SortOrderDescending indicates that the resources are sorted in descending order.
+	SortOrderDescending SortOrder = "descending"
+)
+
+// SortResources sorts the given resources of the given resource type based on the "sortBy" and "sortOrder" of the
+// given parameters. The resources are left untouched if no "sortBy" parameter is present.
+//
+// The attribute type and the "caseExact" characteristic of the attribute determine how the values get compared.
+// Multi-valued attributes are sorted by their primary value, or else by their first value. Resources without a value
+// for the attribute are sorted last in ascending order, and first in descending order.
+// More info: https://datatracker.ietf.org/doc/html/rfc7644#section-3.4.2.3
+func SortResources(resourceType ResourceType, params ListRequestParams, resources []Resource) {
+	if params.SortBy == nil {
+		return
+	}
+
+	attr, ok := resourceType.sortAttribute(*params.SortBy)
+	if !ok {
+		return
+	}
+	values := make([]interface{}, len(resources))
+	for i, r := range resources {
+		values[i] = resourceType.sortValue(r, *params.SortBy)
+	}
+
+	sorted := make([]int, len(resources))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := values[sorted[i]], values[sorted[j]]
+		if a == nil || b == nil {
+			// Missing values are treated as the greatest values.
+			if params.SortOrder == SortOrderDescending {
+				return a == nil && b != nil
+			}
+			return a != nil && b == nil
+		}
+		if params.SortOrder == SortOrderDescending {
+			return compareValues(attr, a, b) > 0
+		}
+		return compareValues(attr, a, b) < 0
+	})
+
+	original := make([]Resource, len(resources))
+	copy(original, resources)
+	for i, j := range sorted {
+		resources[i] = original[j]
+	}
+}
+
+// compareValues compares the two given values of the given attribute. The result will be 0 if a == b, -1 if a < b and
+// +1 if a > b.
+func compareValues(attr schema.CoreAttribute, a, b interface{}) int {
+	switch attr.AttributeType() {
+	case "boolean":
+		x, okA := a.(bool)
+		y, okB := b.(bool)
+		if okA && okB {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			default:
+				return 1
+			}
+		}
+	case "dateTime":
+		x, errA := datetime.Parse(fmt.Sprint(a))
+		y, errB := datetime.Parse(fmt.Sprint(b))
+		if errA == nil && errB == nil {
+			switch {
+			case x.Equal(y):
+				return 0
+			case x.Before(y):
+				return -1
+			default:
+				return 1
+			}
+		}
+	case "decimal", "integer":
+		x, okA := numeric.Float(a)
+		y, okB := numeric.Float(b)
+		if okA && okB {
+			switch {
+			case x == y:
+				return 0
+			case x < y:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+
+	x, y := fmt.Sprint(a), fmt.Sprint(b)
+	if !attr.CaseExact() {
+		x, y = strings.ToLower(x), strings.ToLower(y)
+	}
+	return strings.Compare(x, y)
+}
+
+// sortAttribute returns the attribute of the resource type that is referenced by the given attribute path. In the case
+// of a complex attribute without sub-attribute, the "value" sub-attribute is returned.
+func (t ResourceType) sortAttribute(path filter.AttributePath) (schema.CoreAttribute, bool) {
+	_, attr, ok := getAttribute(path, t.Schema, t.getSchemaExtensions()...)
+	if !ok {
+		return schema.CoreAttribute{}, false
+	}
+	if !attr.HasSubAttributes() {
+		return attr, true
+	}
+	subAttrName := path.SubAttributeName()
+	if subAttrName == "" {
+		subAttrName = "value"
+	}
+	return attr.SubAttributes().ContainsAttribute(subAttrName)
+}
+
+// sortValue returns the value of the given resource that is referenced by the given attribute path, or nil if the
+// resource has no such value.
+func (t ResourceType) sortValue(r Resource, path filter.AttributePath) interface{} {
+	s, attr, ok := getAttribute(path, t.Schema, t.getSchemaExtensions()...)
+	if !ok {
+		return nil
+	}
+
+	var value interface{}
+	switch name := attr.Name(); {
+	case s.ID == t.Schema.ID && name == schema.CommonAttributeID:
+		value = r.ID
+	case s.ID == t.Schema.ID && name == schema.CommonAttributeExternalID && r.ExternalID.Present():
+		value = r.ExternalID.Value()
+	case s.ID == t.Schema.ID && name == "meta":
+		value = r.Meta.sortValue(t)
+	default:
+		attributes := map[string]interface{}(r.Attributes)
+		if s.ID != t.Schema.ID {
+			attributes, ok = asMap(lookupFold(r.Attributes, s.ID))
+			if !ok {
+				return nil
+			}
+		}
+		value = lookupFold(attributes, name)
+	}
+
+	value = primaryValue(value)
+	if !attr.HasSubAttributes() {
+		return value
+	}
+	complexValue, ok := asMap(value)
+	if !ok {
+		return nil
+	}
+	subAttrName := path.SubAttributeName()
+	if subAttrName == "" {
+		subAttrName = "value"
+	}
+	return lookupFold(complexValue, subAttrName)
+}
+
+// sortValue returns the meta attributes as they are used to sort resources.
+func (m Meta) sortValue(resourceType ResourceType) map[string]interface{} {
+	value := map[string]interface{}{
+		"resourceType": resourceType.Name,
+	}
+	if m.Created != nil {
+		value["created"] = m.Created.Format(time.RFC3339)
+	}
+	if m.LastModified != nil {
+		value["lastModified"] = m.LastModified.Format(time.RFC3339)
+	}
+	if m.Version != "" {
+		value["version"] = m.Version
+	}
+	return value
+}
+
+// lookupFold returns the value of the given key within the given map, the key is matched case-insensitively.
+func lookupFold(m map[string]interface{}, key string) interface{} {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+// primaryValue returns the primary value of the given multi-valued value, or the first value if no value is marked as
+// primary. Single values are returned as is.
+func primaryValue(value interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice {
+		return value
+	}
+	if rv.Len() == 0 {
+		return nil
+	}
+	for i := 0; i < rv.Len(); i++ {
+		v := rv.Index(i).Interface()
+		if complexValue, ok := asMap(v); ok {
+			if primary, ok := complexValue["primary"].(bool); ok && primary {
+				return v
+			}
+		}
+	}
+	return rv.Index(0).Interface()
+}
@@ -0,0 +1,250 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func newTestSortResourceType() ResourceType {
+	return ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema: schema.Schema{
+			ID: "urn:ietf:params:scim:schemas:core:2.0:User",
+			Attributes: []schema.CoreAttribute{
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name: "userName",
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					CaseExact: true,
+					Name:      "nickName",
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleBooleanParams(schema.BooleanParams{
+					Name: "active",
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+					Name: "age",
+					Type: schema.AttributeTypeInteger(),
+				})),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					MultiValued: true,
+					Name:        "emails",
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{Name: "value"}),
+						schema.SimpleBooleanParams(schema.BooleanParams{Name: "primary"}),
+					},
+				}),
+			},
+		},
+		SchemaExtensions: []SchemaExtension{
+			{Schema: schema.ExtensionEnterpriseUser()},
+		},
+	}
+}
+
+func TestSortResources(t *testing.T) {
+	created := func(day int) *time.Time {
+		t := time.Date(2020, time.January, day, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	resources := []Resource{
+		{
+			ID:         "1",
+			ExternalID: optional.NewString("c"),
+			Attributes: ResourceAttributes{
+				"userName": "bob",
+				"nickName": "bob",
+				"active":   true,
+				"age":      json.Number("30"),
+				"emails": []interface{}{
+					map[string]interface{}{"value": "z@example.com"},
+					map[string]interface{}{"value": "b@example.com", "primary": true},
+				},
+			},
+			Meta: Meta{Created: created(2)},
+		},
+		{
+			ID:         "2",
+			ExternalID: optional.NewString("a"),
+			Attributes: ResourceAttributes{
+				"userName": "Alice",
+				"nickName": "Alice",
+				"active":   false,
+				"age":      json.Number("9"),
+				"emails": []interface{}{
+					map[string]interface{}{"value": "c@example.com"},
+				},
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]interface{}{
+					"employeeNumber": "2",
+				},
+			},
+			Meta: Meta{Created: created(3)},
+		},
+		{
+			ID:         "3",
+			ExternalID: optional.NewString("b"),
+			Attributes: ResourceAttributes{
+				"userName": "carol",
+				"nickName": "carol",
+				"age":      json.Number("25"),
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]interface{}{
+					"employeeNumber": "1",
+				},
+			},
+			Meta: Meta{Created: created(1)},
+		},
+	}
+
+	tests := []struct {
+		sortBy    string
+		sortOrder SortOrder
+		expected  []string
+	}{
+		{sortBy: "userName", expected: []string{"2", "1", "3"}},
+		{sortBy: "userName", sortOrder: SortOrderDescending, expected: []string{"3", "1", "2"}},
+		{sortBy: "nickName", expected: []string{"2", "1", "3"}},
+		{sortBy: "age", expected: []string{"2", "3", "1"}},
+		{sortBy: "active", expected: []string{"2", "1", "3"}},
+		{sortBy: "active", sortOrder: SortOrderDescending, expected: []string{"3", "1", "2"}},
+		{sortBy: "emails", expected: []string{"1", "2", "3"}},
+		{sortBy: "emails.value", sortOrder: SortOrderDescending, expected: []string{"3", "2", "1"}},
+		{sortBy: "externalId", expected: []string{"2", "3", "1"}},
+		{sortBy: "meta.created", expected: []string{"3", "1", "2"}},
+		{sortBy: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", expected: []string{"3", "2", "1"}},
+		{sortBy: "employeeNumber", expected: []string{"3", "2", "1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %s", test.sortBy, test.sortOrder), func(t *testing.T) {
+			sortBy, err := filter.ParseAttrPath([]byte(test.sortBy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sortOrder := test.sortOrder
+			if sortOrder == "" {
+				sortOrder = SortOrderAscending
+			}
+
+			sorted := make([]Resource, len(resources))
+			copy(sorted, resources)
+			SortResources(newTestSortResourceType(), ListRequestParams{
+				SortBy:    &sortBy,
+				SortOrder: sortOrder,
+			}, sorted)
+
+			ids := make([]string, len(sorted))
+			for i, r := range sorted {
+				ids[i] = r.ID
+			}
+			assertEqualStrings(t, test.expected, ids)
+		})
+	}
+}
+
+func TestSortResourcesCaseExact(t *testing.T) {
+	resources := []Resource{
+		{ID: "1", Attributes: ResourceAttributes{"userName": "b", "nickName": "b"}},
+		{ID: "2", Attributes: ResourceAttributes{"userName": "B", "nickName": "B"}},
+		{ID: "3", Attributes: ResourceAttributes{"userName": "a", "nickName": "a"}},
+	}
+
+	sortBy := filter.AttributePath{AttributeName: "nickName"}
+	SortResources(newTestSortResourceType(), ListRequestParams{
+		SortBy:    &sortBy,
+		SortOrder: SortOrderAscending,
+	}, resources)
+	// Upper case letters precede lower case letters if the attribute is case exact.
+	assertEqualStrings(t, []string{"2", "3", "1"}, []string{resources[0].ID, resources[1].ID, resources[2].ID})
+
+	sortBy = filter.AttributePath{AttributeName: "userName"}
+	SortResources(newTestSortResourceType(), ListRequestParams{
+		SortBy:    &sortBy,
+		SortOrder: SortOrderAscending,
+	}, resources)
+	// Equal values keep their relative order.
+	assertEqualStrings(t, []string{"3", "2", "1"}, []string{resources[0].ID, resources[1].ID, resources[2].ID})
+}
+
+func TestServerResourcesGetHandlerSort(t *testing.T) {
+	s := newTestServer()
+	s.ResourceTypes[0].SortPages = true
+
+	req := httptest.NewRequest(http.MethodGet, "/Users?sortBy=userName&sortOrder=descending", nil)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	var response listResponse
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertLen(t, response.Resources, 20)
+	for i, resource := range response.Resources {
+		resource, ok := resource.(map[string]interface{})
+		assertTypeOk(t, ok, "object")
+		assertEqual(t, fmt.Sprintf("test%02d", 20-i), resource["userName"])
+	}
+}
+
+func TestServerResourcesGetHandlerSortInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "unknown attribute", query: "sortBy=invalid"},
+		{name: "value expression", query: "sortBy=emails[type%20eq%20%22work%22].value"},
+		{name: "invalid order", query: "sortBy=userName&sortOrder=random"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/Users?"+test.query, nil)
+			rr := httptest.NewRecorder()
+			newTestServer().ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestServerResourcesGetHandlerSortParams(t *testing.T) {
+	params, scimErr := newTestServer().parseListRequestParams(map[string][]string{
+		"sortBy": {"meta.lastModified"},
+	}, getUserSchema())
+	if scimErr != nil {
+		t.Fatal(scimErr)
+	}
+	assertNotNil(t, params.SortBy, "sortBy")
+	assertEqual(t, "meta.lastModified", params.SortBy.String())
+	assertEqual(t, SortOrderAscending, params.SortOrder)
+
+	params, _ = newTestServer().parseListRequestParams(map[string][]string{}, getUserSchema())
+	if params.SortBy != nil {
+		t.Errorf("expected no sortBy, got %s", params.SortBy)
+	}
+	assertEqual(t, SortOrder(""), params.SortOrder)
+}
+
+func TestServerResourcesGetHandlerSortNotSupported(t *testing.T) {
+	s := newTestServer()
+	s.Config.SupportSort = false
+
+	params, scimErr := s.parseListRequestParams(map[string][]string{
+		"sortBy":    {"invalid"},
+		"sortOrder": {"random"},
+	}, getUserSchema())
+	if scimErr != nil {
+		t.Fatal(scimErr)
+	}
+	if params.SortBy != nil {
+		t.Errorf("expected no sortBy, got %s", params.SortBy)
+	}
+	assertEqual(t, SortOrder(""), params.SortOrder)
+}
@@ -0,0 +1,219 @@
+package scim
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// Tenant customizes the server for a single tenant. The zero value serves the tenant with the configuration of the
+// server itself.
+type Tenant struct {
+	// Config overrides the service provider config of the server, if not nil.
+	Config *ServiceProviderConfig
+	// SchemaExtensions maps the names of resource types on the schema extensions that are added to the resource type
+	// for this tenant, e.g., custom attributes of a customer. They are returned by the discovery endpoints and used to
+	// validate the requests of the tenant.
+	SchemaExtensions map[string][]SchemaExtension
+	// BaseURL overrides the base URL of the server, see Server.BaseURL. It is required if the base URL of the server
+	// is configured and the tenant is resolved from the path of the requests.
+	BaseURL string
+}
+
+// apply returns a copy of the given server that is customized for the tenant.
+func (t Tenant) apply(s Server) Server {
+	if t.Config != nil {
+		s.Config = *t.Config
+	}
+	if t.BaseURL != "" {
+		s.BaseURL = t.BaseURL
+	}
+	if len(t.SchemaExtensions) != 0 {
+		resourceTypes := make([]ResourceType, len(s.ResourceTypes))
+		for i, resourceType := range s.ResourceTypes {
+			if extensions := t.SchemaExtensions[resourceType.Name]; len(extensions) != 0 {
+				resourceType.SchemaExtensions = append(
+					append([]SchemaExtension(nil), resourceType.SchemaExtensions...),
+					extensions...,
+				)
+			}
+			resourceTypes[i] = resourceType
+		}
+		s.ResourceTypes = resourceTypes
+	}
+	return s
+}
+
+// TenantResolver resolves the tenant of a request. It returns the identifier of the tenant and the path prefix of the
+// endpoint of the tenant, e.g., "/tenants/acme", which is stripped from the path of the request. An empty identifier
+// is returned if the tenant can not be resolved.
+//
+// Resolvers are called before the request is authenticated, and again after authentication if the tenant could not
+// be resolved yet. This way the tenant can also be resolved from the authenticated principal, see TenantFromClaim.
+// Other resolvers do not check whether the principal belongs to the tenant, see Server.TenantAuthorizer.
+// Errors are returned to the client as a 500 Internal Server Error response, unless a SCIM error is returned.
+type TenantResolver func(r *http.Request) (tenant string, prefix string, err error)
+
+// TenantFromPathPrefix returns a tenant resolver that resolves the tenant from the path segment that follows the given
+// prefix, e.g., "acme" for "/tenants/acme/v2/Users" if the prefix is "/tenants".
+func TenantFromPathPrefix(prefix string) TenantResolver {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	if prefix == "//" {
+		prefix = "/"
+	}
+	return func(r *http.Request) (string, string, error) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			return "", "", nil
+		}
+		tenant := strings.SplitN(strings.TrimPrefix(r.URL.Path, prefix), "/", 2)[0]
+		if tenant == "" {
+			return "", "", nil
+		}
+		return tenant, prefix + tenant, nil
+	}
+}
+
+// TenantFromHost returns a tenant resolver that resolves the tenant from the subdomain of the given domain in the host
+// of the request, e.g., "acme" for "acme.scim.example.com" if the domain is "scim.example.com".
+func TenantFromHost(domain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(r *http.Request) (string, string, error) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return "", "", nil
+		}
+		tenant := strings.TrimSuffix(host, suffix)
+		if tenant == "" || strings.Contains(tenant, ".") {
+			return "", "", nil
+		}
+		return tenant, "", nil
+	}
+}
+
+// TenantFromClaim returns a tenant resolver that resolves the tenant from the claim with the given name of the
+// authenticated principal, e.g., a "tenant" claim of a bearer token.
+func TenantFromClaim(claim string) TenantResolver {
+	return func(r *http.Request) (string, string, error) {
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			return "", "", nil
+		}
+		tenant, _ := principal.Claims[claim].(string)
+		return tenant, "", nil
+	}
+}
+
+// TenantAuthorizer reports whether the given authenticated principal belongs to the tenant with the given identifier.
+type TenantAuthorizer func(tenant string, principal Principal) bool
+
+// TenantClaimAuthorizer returns a tenant authorizer that only permits the principals of which the claim with the given
+// name equals the identifier of the tenant, e.g., a "tenant" claim of a bearer token.
+func TenantClaimAuthorizer(claim string) TenantAuthorizer {
+	return func(tenant string, principal Principal) bool {
+		value, ok := principal.Claims[claim].(string)
+		return ok && value == tenant
+	}
+}
+
+// tenantKey is the context key of the tenant of a request.
+type tenantKey struct{}
+
+// tenantContext is the tenant of a request.
+type tenantContext struct {
+	id     string
+	prefix string
+}
+
+// TenantFromContext returns the identifier of the tenant that was resolved by the tenant resolver of the server. The
+// second return value is false if the tenant is unknown, e.g., if the server has no tenant resolver.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(tenantContext)
+	return tenant.id, ok
+}
+
+// WithTenant returns a copy of the given context that contains the given tenant identifier. This can be used to pass
+// the tenant to handlers outside of an HTTP request, e.g., in batch jobs.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantContext{id: tenant})
+}
+
+// tenantPrefix returns the path prefix of the endpoint of the tenant of the given request.
+func tenantPrefix(r *http.Request) string {
+	tenant, _ := r.Context().Value(tenantKey{}).(tenantContext)
+	return tenant.prefix
+}
+
+// resolveTenant resolves the tenant of the given request, if not resolved yet. It returns the request that contains
+// the tenant, without the path prefix of the endpoint of the tenant.
+func (s Server) resolveTenant(r *http.Request) (*http.Request, *errors.ScimError) {
+	if s.TenantResolver == nil {
+		return r, nil
+	}
+	if _, ok := TenantFromContext(r.Context()); ok {
+		return r, nil
+	}
+
+	id, prefix, err := s.TenantResolver(r)
+	if err != nil {
+		scimErr := errors.CheckScimError(err, r.Method)
+		return r, &scimErr
+	}
+	if id == "" {
+		return r, nil
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	r = r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenantContext{id: id, prefix: prefix}))
+	if prefix != "" {
+		u := *r.URL
+		u.Path = strings.TrimPrefix(u.Path, prefix)
+		u.RawPath = ""
+		r.URL = &u
+	}
+	return r, nil
+}
+
+// forTenant returns the server that is customized for the tenant of the given request, see Tenant. Requests of which
+// the tenant can not be resolved are rejected, except for the discovery endpoints if they are public.
+func (s Server) forTenant(r *http.Request, public bool) (Server, *http.Request, *errors.ScimError) {
+	if s.TenantResolver == nil {
+		return s, r, nil
+	}
+
+	r, scimErr := s.resolveTenant(r)
+	if scimErr != nil {
+		return s, r, scimErr
+	}
+	id, ok := TenantFromContext(r.Context())
+	if !ok {
+		if public {
+			return s, r, nil
+		}
+		return s, r, &errors.ScimError{
+			Detail: "The tenant of the request could not be resolved.",
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if principal, ok := PrincipalFromContext(r.Context()); ok && s.TenantAuthorizer != nil && !s.TenantAuthorizer(id, principal) {
+		scimErr := errors.ScimErrorForbidden(fmt.Sprintf("The client is not permitted to access tenant %s.", id))
+		return s, r, &scimErr
+	}
+
+	tenant, ok := s.Tenants[id]
+	if !ok && s.Tenants != nil {
+		return s, r, &errors.ScimError{
+			Detail: fmt.Sprintf("Tenant %s not found.", id),
+			Status: http.StatusNotFound,
+		}
+	}
+	return tenant.apply(s), r, nil
+}
@@ -0,0 +1,205 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestServerTenantFromPathPrefix(t *testing.T) {
+	var tenants []string
+	s := newTestServer()
+	s.TenantResolver = TenantFromPathPrefix("/tenants")
+	s.Tenants = map[string]Tenant{
+		"acme": {
+			SchemaExtensions: map[string][]SchemaExtension{
+				"User": {{Schema: schema.Schema{
+					ID:   "urn:example:params:scim:schemas:extension:acme:2.0:User",
+					Name: optional.NewString("AcmeUser"),
+					Attributes: []schema.CoreAttribute{
+						schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+							Name: "costCenter",
+						})),
+					},
+				}}},
+			},
+		},
+		"globex": {},
+	}
+	s.BeforeHooks = []BeforeHook{
+		func(r *http.Request, input OperationInput) (OperationInput, error) {
+			tenant, _ := TenantFromContext(r.Context())
+			tenants = append(tenants, tenant)
+			return input, nil
+		},
+	}
+	s.DeriveBaseURL = true
+
+	schemas := func(target string) []string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Resources []struct {
+				ID string
+			}
+		}
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		var ids []string
+		for _, resource := range response.Resources {
+			ids = append(ids, resource.ID)
+		}
+		return ids
+	}
+	assertLen(t, schemas("/tenants/acme/v2/Schemas"), 4)
+	assertLen(t, schemas("/tenants/globex/v2/Schemas"), 3)
+
+	// The extension of the tenant is used to validate the requests of the tenant.
+	req := httptest.NewRequest(http.MethodPost, "/tenants/acme/v2/Users", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "urn:example:params:scim:schemas:extension:acme:2.0:User"],
+		"userName": "new",
+		"urn:example:params:scim:schemas:extension:acme:2.0:User": {"costCenter": "42"}
+	}`))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusCreated, rr.Code)
+	assertEqual(t, true, strings.HasPrefix(rr.Header().Get("Location"), "http://example.com/tenants/acme/v2/Users/"))
+	assertEqualStrings(t, []string{"acme"}, tenants)
+
+	for _, target := range []string{"/tenants/initech/v2/Users", "/v2/Users", "/tenants/"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		assertEqualStatusCode(t, http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestServerTenantAuthorizer(t *testing.T) {
+	var tenants []string
+	s := newTestServer()
+	s.TenantResolver = TenantFromPathPrefix("/tenants")
+	s.Tenants = map[string]Tenant{
+		"acme":   {},
+		"globex": {},
+	}
+	s.BeforeHooks = []BeforeHook{
+		func(r *http.Request, input OperationInput) (OperationInput, error) {
+			tenant, _ := TenantFromContext(r.Context())
+			tenants = append(tenants, tenant)
+			return input, nil
+		},
+	}
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{
+			Verify: StaticTokens(map[string]Principal{
+				"acme":    {ID: "client", Claims: map[string]interface{}{"tenant": "acme"}},
+				"unknown": {ID: "client"},
+			}),
+		},
+	}
+	s.TenantAuthorizer = TenantClaimAuthorizer("tenant")
+
+	for _, test := range []struct {
+		target             string
+		token              string
+		expectedStatusCode int
+	}{
+		{target: "/tenants/acme/v2/Users/0001", token: "acme", expectedStatusCode: http.StatusNoContent},
+		{target: "/tenants/globex/v2/Users/0001", token: "acme", expectedStatusCode: http.StatusForbidden},
+		{target: "/tenants/initech/v2/Users/0001", token: "acme", expectedStatusCode: http.StatusForbidden},
+		{target: "/tenants/acme/v2/Users/0001", token: "unknown", expectedStatusCode: http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodDelete, test.target, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		assertEqualStatusCode(t, test.expectedStatusCode, rr.Code)
+	}
+
+	// The resources of other tenants are never accessed.
+	assertEqualStrings(t, []string{"acme"}, tenants)
+}
+
+func TestServerTenantFromHost(t *testing.T) {
+	s := newTestServer()
+	s.TenantResolver = TenantFromHost("scim.example.com")
+	s.Tenants = map[string]Tenant{
+		"acme": {
+			Config: &ServiceProviderConfig{
+				DocumentationURI: optional.NewString("https://acme.example.com/scim"),
+			},
+		},
+		"globex": {},
+	}
+
+	documentationURI := func(host string) interface{} {
+		req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+		var config map[string]interface{}
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &config))
+		return config["documentationUri"]
+	}
+	assertEqual(t, "https://acme.example.com/scim", documentationURI("acme.scim.example.com:443"))
+	assertEqual(t, "", documentationURI("GLOBEX.scim.example.com"))
+
+	req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+	req.Host = "scim.example.com"
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusNotFound, rr.Code)
+}
+
+func TestServerTenantFromClaim(t *testing.T) {
+	var tenants []string
+	s := newTestServer()
+	s.TenantResolver = TenantFromClaim("tenant")
+	s.Tenants = map[string]Tenant{
+		"acme": {},
+	}
+	s.BeforeHooks = []BeforeHook{
+		func(r *http.Request, input OperationInput) (OperationInput, error) {
+			tenant, _ := TenantFromContext(r.Context())
+			tenants = append(tenants, tenant)
+			return input, nil
+		},
+	}
+	s.Authenticators = []Authenticator{
+		BearerTokenAuthenticator{
+			Verify: StaticTokens(map[string]Principal{
+				"acme":    {ID: "client", Claims: map[string]interface{}{"tenant": "acme"}},
+				"unknown": {ID: "client"},
+			}),
+		},
+	}
+	s.PublicDiscovery = true
+
+	// Public discovery endpoints are served with the configuration of the server itself.
+	req := httptest.NewRequest(http.MethodGet, "/Schemas", nil)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/Users/0001", nil)
+	req.Header.Set("Authorization", "Bearer acme")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusNoContent, rr.Code)
+	assertEqualStrings(t, []string{"acme"}, tenants)
+
+	req = httptest.NewRequest(http.MethodGet, "/Users", nil)
+	req.Header.Set("Authorization", "Bearer unknown")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusNotFound, rr.Code)
+}
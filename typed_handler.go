@@ -0,0 +1,181 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+// TypedResource is a resource of which the attributes are represented by a Go struct of type T.
+type TypedResource[T any] struct {
+	// ID is the unique identifier of the resource. It is empty for resources that still need to be created.
+	ID string
+	// ExternalID is an identifier for the resource as defined by the provisioning client.
+	ExternalID optional.String
+	// Attributes are the attributes of the resource, without the "id" and "externalId" attributes.
+	Attributes T
+	// Meta contains dates and the version of the resource.
+	Meta Meta
+}
+
+// TypedPage is a page of typed resources, see Page.
+type TypedPage[T any] struct {
+	// TotalResults is the total number of results returned by the list or query operation.
+	TotalResults int
+	// Resources is the list of resources of the page.
+	Resources []TypedResource[T]
+}
+
+// TypedResourceHandler is the typed alternative of ResourceHandler, of which the resource attributes are represented by
+// a Go struct of type T. Use NewTypedHandler to use it as the handler of a resource type. The methods have the same
+// semantics as the corresponding methods of ResourceHandler.
+type TypedResourceHandler[T any] interface {
+	// Create stores the given resource. Returns the resource that is stored with a (new) unique identifier.
+	Create(r *http.Request, resource TypedResource[T]) (TypedResource[T], error)
+	// Get returns the resource corresponding with the given identifier.
+	Get(r *http.Request, id string) (TypedResource[T], error)
+	// GetAll returns a paginated list of resources.
+	GetAll(r *http.Request, params ListRequestParams) (TypedPage[T], error)
+	// Replace replaces ALL existing attributes of the resource with given identifier.
+	Replace(r *http.Request, id string, resource TypedResource[T]) (TypedResource[T], error)
+	// Delete removes the resource with corresponding ID.
+	Delete(r *http.Request, id string) error
+	// Patch update one or more attributes of a SCIM resource using a sequence of operations.
+	Patch(r *http.Request, id string, operations []PatchOperation) (TypedResource[T], error)
+}
+
+// TypedHandler is a ResourceHandler that decodes the validated resource attributes into a Go struct of type T for a
+// TypedResourceHandler, and encodes the returned structs back into resources.
+//
+// The attributes are decoded and encoded with encoding/json, so the json names of the fields of T need to match the
+// attribute names of the schema, e.g., `json:"userName"`. Schema extensions are represented by fields of which the
+// json name is the URN of the extension, e.g., `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`.
+// The "externalId" attribute is passed as TypedResource.ExternalID instead. Fields that are not set should be omitted
+// from the encoded attributes, e.g., with the "omitempty" option or by using pointers.
+type TypedHandler[T any] struct {
+	handler TypedResourceHandler[T]
+}
+
+// NewTypedHandler returns a ResourceHandler that passes the resources of the requests as Go structs of type T to the
+// given typed resource handler.
+func NewTypedHandler[T any](handler TypedResourceHandler[T]) TypedHandler[T] {
+	return TypedHandler[T]{handler: handler}
+}
+
+// Create decodes the given attributes and passes them to the Create method of the typed resource handler.
+func (h TypedHandler[T]) Create(r *http.Request, attributes ResourceAttributes) (Resource, error) {
+	resource, err := decodeTypedResource[T](attributes)
+	if err != nil {
+		return Resource{}, err
+	}
+	created, err := h.handler.Create(r, resource)
+	if err != nil {
+		return Resource{}, err
+	}
+	return encodeTypedResource(created)
+}
+
+// Delete passes the given id to the Delete method of the typed resource handler.
+func (h TypedHandler[T]) Delete(r *http.Request, id string) error {
+	return h.handler.Delete(r, id)
+}
+
+// Get encodes the resource that is returned by the Get method of the typed resource handler.
+func (h TypedHandler[T]) Get(r *http.Request, id string) (Resource, error) {
+	resource, err := h.handler.Get(r, id)
+	if err != nil {
+		return Resource{}, err
+	}
+	return encodeTypedResource(resource)
+}
+
+// GetAll encodes the resources of the page that is returned by the GetAll method of the typed resource handler.
+func (h TypedHandler[T]) GetAll(r *http.Request, params ListRequestParams) (Page, error) {
+	typedPage, err := h.handler.GetAll(r, params)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{TotalResults: typedPage.TotalResults}
+	if typedPage.Resources != nil {
+		page.Resources = make([]Resource, len(typedPage.Resources))
+	}
+	for i, typedResource := range typedPage.Resources {
+		resource, err := encodeTypedResource(typedResource)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Resources[i] = resource
+	}
+	return page, nil
+}
+
+// Patch encodes the resource that is returned by the Patch method of the typed resource handler.
+func (h TypedHandler[T]) Patch(r *http.Request, id string, operations []PatchOperation) (Resource, error) {
+	resource, err := h.handler.Patch(r, id, operations)
+	if err != nil {
+		return Resource{}, err
+	}
+	return encodeTypedResource(resource)
+}
+
+// Replace decodes the given attributes and passes them to the Replace method of the typed resource handler.
+func (h TypedHandler[T]) Replace(r *http.Request, id string, attributes ResourceAttributes) (Resource, error) {
+	resource, err := decodeTypedResource[T](attributes)
+	if err != nil {
+		return Resource{}, err
+	}
+	resource.ID = id
+	replaced, err := h.handler.Replace(r, id, resource)
+	if err != nil {
+		return Resource{}, err
+	}
+	return encodeTypedResource(replaced)
+}
+
+// decodeTypedResource decodes the given (validated) attributes into a typed resource. Attributes that can not be
+// represented by T result in an invalid value error.
+func decodeTypedResource[T any](attributes ResourceAttributes) (TypedResource[T], error) {
+	var resource TypedResource[T]
+	rest := make(ResourceAttributes, len(attributes))
+	for k, v := range attributes {
+		rest[k] = v
+	}
+	if externalID, ok := rest[schema.CommonAttributeExternalID].(string); ok {
+		resource.ExternalID = optional.NewString(externalID)
+	}
+	delete(rest, schema.CommonAttributeExternalID)
+
+	raw, err := json.Marshal(rest)
+	if err != nil {
+		return TypedResource[T]{}, err
+	}
+	if err := json.Unmarshal(raw, &resource.Attributes); err != nil {
+		return TypedResource[T]{}, errors.ScimErrorInvalidValue
+	}
+	return resource, nil
+}
+
+// encodeTypedResource encodes the given typed resource into a resource. Numbers are represented as a json.Number.
+func encodeTypedResource[T any](typedResource TypedResource[T]) (Resource, error) {
+	raw, err := json.Marshal(typedResource.Attributes)
+	if err != nil {
+		return Resource{}, err
+	}
+	var attributes ResourceAttributes
+	if err := unmarshal(raw, &attributes); err != nil {
+		return Resource{}, err
+	}
+	delete(attributes, schema.CommonAttributeID)
+	delete(attributes, schema.CommonAttributeExternalID)
+
+	return Resource{
+		ID:         typedResource.ID,
+		ExternalID: typedResource.ExternalID,
+		Attributes: attributes,
+		Meta:       typedResource.Meta,
+	}, nil
+}
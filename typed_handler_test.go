@@ -0,0 +1,193 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+type testTypedEmail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type testTypedEnterpriseUser struct {
+	EmployeeNumber string `json:"employeeNumber,omitempty"`
+	Manager        *struct {
+		Value string `json:"value"`
+	} `json:"manager,omitempty"`
+}
+
+type testTypedUser struct {
+	UserName   string                   `json:"userName"`
+	Active     *bool                    `json:"active,omitempty"`
+	Emails     []testTypedEmail         `json:"emails,omitempty"`
+	Enterprise *testTypedEnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+}
+
+// testTypedResourceHandler is an in-memory typed resource handler.
+type testTypedResourceHandler struct {
+	data map[string]TypedResource[testTypedUser]
+}
+
+func (h testTypedResourceHandler) Create(r *http.Request, resource TypedResource[testTypedUser]) (TypedResource[testTypedUser], error) {
+	resource.ID = fmt.Sprintf("%04d", len(h.data)+1)
+	h.data[resource.ID] = resource
+	return resource, nil
+}
+
+func (h testTypedResourceHandler) Delete(r *http.Request, id string) error {
+	if _, ok := h.data[id]; !ok {
+		return errors.ScimErrorResourceNotFound(id)
+	}
+	delete(h.data, id)
+	return nil
+}
+
+func (h testTypedResourceHandler) Get(r *http.Request, id string) (TypedResource[testTypedUser], error) {
+	resource, ok := h.data[id]
+	if !ok {
+		return TypedResource[testTypedUser]{}, errors.ScimErrorResourceNotFound(id)
+	}
+	return resource, nil
+}
+
+func (h testTypedResourceHandler) GetAll(r *http.Request, params ListRequestParams) (TypedPage[testTypedUser], error) {
+	var page TypedPage[testTypedUser]
+	for _, resource := range h.data {
+		page.Resources = append(page.Resources, resource)
+	}
+	page.TotalResults = len(page.Resources)
+	return page, nil
+}
+
+func (h testTypedResourceHandler) Patch(r *http.Request, id string, operations []PatchOperation) (TypedResource[testTypedUser], error) {
+	return h.Get(r, id)
+}
+
+func (h testTypedResourceHandler) Replace(r *http.Request, id string, resource TypedResource[testTypedUser]) (TypedResource[testTypedUser], error) {
+	if _, ok := h.data[id]; !ok {
+		return TypedResource[testTypedUser]{}, errors.ScimErrorResourceNotFound(id)
+	}
+	h.data[id] = resource
+	return resource, nil
+}
+
+func TestTypedHandler(t *testing.T) {
+	handler := testTypedResourceHandler{data: make(map[string]TypedResource[testTypedUser])}
+	s := Server{
+		ResourceTypes: []ResourceType{
+			{
+				ID:       optional.NewString("User"),
+				Name:     "User",
+				Endpoint: "/Users",
+				Schema:   schema.CoreUserSchema(),
+				SchemaExtensions: []SchemaExtension{
+					{Schema: schema.ExtensionEnterpriseUser()},
+				},
+				Handler: NewTypedHandler[testTypedUser](handler),
+			},
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/Users", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "test1",
+		"externalId": "external1",
+		"active": true,
+		"emails": [{"value": "test1@example.com", "primary": true}],
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+			"employeeNumber": "42",
+			"manager": {"value": "0000"}
+		}
+	}`)))
+	assertEqualStatusCode(t, http.StatusCreated, rr.Code)
+
+	stored, ok := handler.data["0001"]
+	if !ok {
+		t.Fatal("resource was not created")
+	}
+	assertEqual(t, "external1", stored.ExternalID.Value())
+	assertEqual(t, "test1", stored.Attributes.UserName)
+	assertNotNil(t, stored.Attributes.Active, "active")
+	assertEqual(t, true, *stored.Attributes.Active)
+	assertLen(t, stored.Attributes.Emails, 1)
+	assertEqual(t, testTypedEmail{Value: "test1@example.com", Primary: true}, stored.Attributes.Emails[0])
+	assertNotNil(t, stored.Attributes.Enterprise, "enterprise")
+	assertEqual(t, "42", stored.Attributes.Enterprise.EmployeeNumber)
+	assertNotNil(t, stored.Attributes.Enterprise.Manager, "manager")
+	assertEqual(t, "0000", stored.Attributes.Enterprise.Manager.Value)
+
+	var created map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assertEqual(t, "0001", created["id"])
+	assertEqual(t, "external1", created["externalId"])
+	assertEqual(t, "test1", created["userName"])
+	assertEqual(t, true, created["active"])
+	assertDeepEqual(t, map[string]interface{}{
+		"employeeNumber": "42",
+		"manager":        map[string]interface{}{"value": "0000"},
+	}, created["urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"])
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/Users/0001", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "test2"
+	}`)))
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	stored = handler.data["0001"]
+	assertEqual(t, "0001", stored.ID)
+	assertEqual(t, false, stored.ExternalID.Present())
+	assertEqual(t, "test2", stored.Attributes.UserName)
+	assertEqual(t, true, stored.Attributes.Enterprise == nil)
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/Users?filter=userName+eq+%22test2%22", nil))
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	var list map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	assertEqual(t, float64(1), list["totalResults"])
+	resources, ok := list["Resources"].([]interface{})
+	assertTypeOk(t, ok, "[]interface{}")
+	assertLen(t, resources, 1)
+	assertDeepEqual(t, map[string]interface{}{
+		"id":       "0001",
+		"userName": "test2",
+		"schemas": []interface{}{
+			"urn:ietf:params:scim:schemas:core:2.0:User",
+			"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+		},
+		"meta": map[string]interface{}{
+			"resourceType": "User",
+			"location":     "Users/0001",
+		},
+	}, resources[0])
+}
+
+func TestTypedHandlerInvalidValue(t *testing.T) {
+	type testTypedCount struct {
+		UserName string `json:"userName"`
+		Count    int8   `json:"count"`
+	}
+	resource, err := decodeTypedResource[testTypedCount](ResourceAttributes{
+		"userName":   "test",
+		"externalId": "external",
+		"count":      json.Number("42"),
+	})
+	assertNil(t, err, "error")
+	assertEqual(t, TypedResource[testTypedCount]{
+		ExternalID: optional.NewString("external"),
+		Attributes: testTypedCount{UserName: "test", Count: 42},
+	}, resource)
+
+	_, err = decodeTypedResource[testTypedCount](ResourceAttributes{"count": 1000})
+	assertEqual(t, errors.ScimErrorInvalidValue, err)
+}
@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"strings"
+
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
 )
 
 func clamp(offset, limit, length int) (int, int) {
@@ -28,6 +32,24 @@ func contains(arr []string, el string) bool {
 	return false
 }
 
+// getAttribute returns the schema and the attribute to which the given attribute path applies. Besides the given schema
+// and extensions, the path can also refer to one of the common attributes.
+func getAttribute(path filter.AttributePath, s schema.Schema, extensions ...schema.Schema) (schema.Schema, schema.CoreAttribute, bool) {
+	common := schema.Schema{
+		ID:         s.ID,
+		Attributes: schema.CommonAttributes(),
+	}
+	for _, ref := range append([]schema.Schema{s, common}, extensions...) {
+		if uri := path.URI(); uri != "" && !strings.EqualFold(uri, ref.ID) {
+			continue
+		}
+		if attr, ok := ref.Attributes.ContainsAttribute(path.AttributeName); ok {
+			return ref, attr, true
+		}
+	}
+	return schema.Schema{}, schema.CoreAttribute{}, false
+}
+
 func readBody(r *http.Request) ([]byte, error) {
 	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -6,6 +6,12 @@ import (
 	"testing"
 )
 
+func assertDeepEqual(t *testing.T, expected, actual interface{}) {
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("not equal: expected %v, actual %v", expected, actual)
+	}
+}
+
 func assertEqual(t *testing.T, expected, actual interface{}) {
 	if expected != actual {
 		t.Errorf("not equal: expected %v, actual %v", expected, actual)